@@ -1,21 +1,29 @@
 #!/usr/bin/env bash
 
-set -e
+# Sends the sample events to a local Mattermost server. Set WEBHOOK_SECRET to the plugin's "Webhook
+# Secret" to sign them, or enable "Allow Unsigned Webhooks" to send them unsigned.
 
-curl -H "content-type: application/json" -H "X-GitHub-Event: issues" -d @sample/issue.json \
-  http://localhost:8065/plugins/org.holochain.mm-plugin/github
+set -e
 
-curl -H "content-type: application/json" -H "X-GitHub-Event: pull_request" -d @sample/pull_request_1.json \
-  http://localhost:8065/plugins/org.holochain.mm-plugin/github
+URL=${URL:-http://localhost:8065/plugins/org.holochain.mm-plugin/github}
 
-curl -H "content-type: application/json" -H "X-GitHub-Event: pull_request" -d @sample/pull_request_2.json \
-  http://localhost:8065/plugins/org.holochain.mm-plugin/github
+send() {
+  local event=$1 file=$2
+  local headers=(-H "content-type: application/json" -H "X-GitHub-Event: $event")
 
-curl -H "content-type: application/json" -H "X-GitHub-Event: pull_request" -d @sample/pull_request_closed_2.json \
-  http://localhost:8065/plugins/org.holochain.mm-plugin/github
+  if [ -n "$WEBHOOK_SECRET" ]; then
+    local signature
+    signature=$(openssl dgst -sha256 -hmac "$WEBHOOK_SECRET" -r "$file" | cut -d ' ' -f 1)
+    headers+=(-H "X-Hub-Signature-256: sha256=$signature")
+  fi
 
-curl -H "content-type: application/json" -H "X-GitHub-Event: release" -d @sample/prerelease.json \
-  http://localhost:8065/plugins/org.holochain.mm-plugin/github
+  curl "${headers[@]}" --data-binary @"$file" "$URL"
+  echo
+}
 
-curl -H "content-type: application/json" -H "X-GitHub-Event: release" -d @sample/release.json \
-  http://localhost:8065/plugins/org.holochain.mm-plugin/github
+send issues sample/issue.json
+send pull_request sample/pull_request_1.json
+send pull_request sample/pull_request_2.json
+send pull_request sample/pull_request_closed_2.json
+send release sample/prerelease.json
+send release sample/release.json
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cbrgm/githubevents/v2/githubevents"
+	"github.com/google/go-github/v76/github"
+	"github.com/mattermost/mattermost/server/public/pluginapi"
+)
+
+const (
+	activityKeyPrefix     = "activity_"
+	activityKeyDateFormat = "2006-01-02"
+	openPullRequestsKey   = "open_pull_requests"
+
+	// activityTTL keeps activities long enough for a weekly digest that runs late.
+	activityTTL = 15 * 24 * time.Hour
+
+	maxAtomicUpdateAttempts = 5
+)
+
+// Kinds of activity summarized in digests.
+const (
+	activityPullRequestOpened = "pull_request_opened"
+	activityPullRequestMerged = "pull_request_merged"
+	activityIssueOpened       = "issue_opened"
+	activityIssueClosed       = "issue_closed"
+	activityReleasePublished  = "release_published"
+)
+
+// activity is an event that is summarized in digests. Activities are stored in one KV entry per
+// UTC day, and expire once they are too old to appear in a weekly digest.
+type activity struct {
+	Time time.Time `json:"time"`
+	Kind string    `json:"kind"`
+	// Event is kept so that digests only include the activity that was routed to their channel.
+	Event  routedEvent `json:"event"`
+	Number int         `json:"number,omitempty"`
+	Title  string      `json:"title"`
+	URL    string      `json:"url"`
+	Author string      `json:"author"`
+}
+
+func activityKey(day time.Time) string {
+	return activityKeyPrefix + day.UTC().Format(activityKeyDateFormat)
+}
+
+// trackedPullRequest is an open pull request with the time of the last event seen for it, which
+// is used to find stale pull requests.
+type trackedPullRequest struct {
+	Event        routedEvent `json:"event"`
+	RepoID       int64       `json:"repo_id"`
+	Number       int         `json:"number"`
+	Title        string      `json:"title"`
+	URL          string      `json:"url"`
+	Author       string      `json:"author"`
+	Reviewers    []string    `json:"reviewers,omitempty"`
+	Draft        bool        `json:"draft"`
+	LastActivity time.Time   `json:"last_activity"`
+
+	// OwnerName is the name of the repository's owner that hashtags use, if it has one.
+	OwnerName string `json:"owner_name,omitempty"`
+}
+
+// object returns the pull request's object in the post index.
+func (t trackedPullRequest) object() githubObject {
+	owner, name, _ := strings.Cut(t.Event.Repository, "/")
+	repo := &github.Repository{
+		ID:    github.Ptr(t.RepoID),
+		Name:  github.Ptr(name),
+		Owner: &github.User{Login: github.Ptr(owner), Name: github.Ptr(t.OwnerName)},
+	}
+
+	return pullRequestObject(repo, &github.PullRequest{Number: github.Ptr(t.Number)})
+}
+
+func trackedPullRequestKey(repo *github.Repository, pullRequest *github.PullRequest) string {
+	return strconv.FormatInt(repo.GetID(), 10) + "_" + strconv.Itoa(pullRequest.GetNumber())
+}
+
+// registerActivityHandlers records the activity that digests are built from, and keeps track of
+// the open pull requests and when they were last active.
+func (p *Plugin) registerActivityHandlers(eventHandler *githubevents.EventHandler) {
+	eventHandler.OnPullRequestEventAny(
+		func(ctx context.Context, deliveryID string, eventName string, event *github.PullRequestEvent) error {
+			repo := event.GetRepo()
+			pullRequest := event.GetPullRequest()
+
+			var errs []error
+			switch event.GetAction() {
+			case githubevents.PullRequestEventOpenedAction:
+				errs = append(errs, p.recordActivity(activityPullRequestOpened, pullRequestRoutedEvent(event),
+					pullRequest.GetNumber(), pullRequest.GetTitle(), pullRequest.GetHTMLURL(), pullRequest.GetUser().GetLogin()))
+			case githubevents.PullRequestEventClosedAction:
+				if pullRequest.GetMerged() {
+					errs = append(errs, p.recordActivity(activityPullRequestMerged, pullRequestRoutedEvent(event),
+						pullRequest.GetNumber(), pullRequest.GetTitle(), pullRequest.GetHTMLURL(), pullRequest.GetUser().GetLogin()))
+				}
+				errs = append(errs, p.untrackPullRequest(repo, pullRequest))
+
+				return errors.Join(errs...)
+			}
+
+			errs = append(errs, p.trackPullRequest(repo, pullRequest))
+
+			return errors.Join(errs...)
+		})
+
+	eventHandler.OnPullRequestReviewEventAny(
+		func(ctx context.Context, deliveryID string, eventName string, event *github.PullRequestReviewEvent) error {
+			return p.trackPullRequest(event.GetRepo(), event.GetPullRequest())
+		})
+
+	eventHandler.OnPullRequestReviewCommentEventAny(
+		func(ctx context.Context, deliveryID string, eventName string, event *github.PullRequestReviewCommentEvent) error {
+			return p.trackPullRequest(event.GetRepo(), event.GetPullRequest())
+		})
+
+	eventHandler.OnIssuesEventOpened(
+		func(ctx context.Context, deliveryID string, eventName string, event *github.IssuesEvent) error {
+			issue := event.GetIssue()
+			return p.recordActivity(activityIssueOpened, issuesRoutedEvent(event),
+				issue.GetNumber(), issue.GetTitle(), issue.GetHTMLURL(), issue.GetUser().GetLogin())
+		})
+
+	eventHandler.OnIssuesEventClosed(
+		func(ctx context.Context, deliveryID string, eventName string, event *github.IssuesEvent) error {
+			issue := event.GetIssue()
+			return p.recordActivity(activityIssueClosed, issuesRoutedEvent(event),
+				issue.GetNumber(), issue.GetTitle(), issue.GetHTMLURL(), issue.GetUser().GetLogin())
+		})
+
+	eventHandler.OnReleaseEventPublished(
+		func(ctx context.Context, deliveryID string, eventName string, event *github.ReleaseEvent) error {
+			release := event.GetRelease()
+
+			title := release.GetName()
+			if title == "" {
+				title = release.GetTagName()
+			}
+
+			return p.recordActivity(activityReleasePublished, releaseRoutedEvent(event),
+				0, title, release.GetHTMLURL(), release.GetAuthor().GetLogin())
+		})
+}
+
+// recordActivity appends an activity to the current day's activities.
+func (p *Plugin) recordActivity(kind string, event routedEvent, number int, title, url, author string) error {
+	now := time.Now()
+	entry := activity{
+		Time:   now,
+		Kind:   kind,
+		Event:  event,
+		Number: number,
+		Title:  title,
+		URL:    url,
+		Author: author,
+	}
+
+	err := p.updateWithExpiry(activityKey(now), activityTTL, func(oldValue []byte) (any, error) {
+		var activities []activity
+		if oldValue != nil {
+			if err := json.Unmarshal(oldValue, &activities); err != nil {
+				return nil, err
+			}
+		}
+
+		return append(activities, entry), nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record activity for %s: %w", url, err)
+	}
+
+	return nil
+}
+
+// getActivities returns the activities that happened in the time range.
+func (p *Plugin) getActivities(from, to time.Time) ([]activity, error) {
+	var activities []activity
+	for day := from.UTC().Truncate(24 * time.Hour); day.Before(to); day = day.Add(24 * time.Hour) {
+		var dayActivities []activity
+		if err := p.client.KV.Get(activityKey(day), &dayActivities); err != nil {
+			return nil, fmt.Errorf("failed to get activities of %s: %w", day.Format(activityKeyDateFormat), err)
+		}
+
+		for _, a := range dayActivities {
+			if !a.Time.Before(from) && a.Time.Before(to) {
+				activities = append(activities, a)
+			}
+		}
+	}
+
+	return activities, nil
+}
+
+// updateWithExpiry atomically replaces the value of the key with the result of update, and lets
+// it expire after ttl.
+func (p *Plugin) updateWithExpiry(key string, ttl time.Duration, update func(oldValue []byte) (any, error)) error {
+	for range maxAtomicUpdateAttempts {
+		var oldValue []byte
+		if err := p.client.KV.Get(key, &oldValue); err != nil {
+			return err
+		}
+
+		newValue, err := update(oldValue)
+		if err != nil {
+			return err
+		}
+
+		saved, err := p.client.KV.Set(key, newValue, pluginapi.SetAtomic(oldValue), pluginapi.SetExpiry(ttl))
+		if err != nil {
+			return err
+		}
+		if saved {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("value of %s changed concurrently too often", key)
+}
+
+// getTrackedPullRequests returns the open pull requests that have been seen.
+func (p *Plugin) getTrackedPullRequests() (map[string]trackedPullRequest, error) {
+	pullRequests := map[string]trackedPullRequest{}
+	if err := p.client.KV.Get(openPullRequestsKey, &pullRequests); err != nil {
+		return nil, fmt.Errorf("failed to get open pull requests: %w", err)
+	}
+
+	return pullRequests, nil
+}
+
+func (p *Plugin) updateTrackedPullRequests(update func(pullRequests map[string]trackedPullRequest)) error {
+	err := p.client.KV.SetAtomicWithRetries(openPullRequestsKey, func(oldValue []byte) (any, error) {
+		pullRequests := map[string]trackedPullRequest{}
+		if oldValue != nil {
+			if err := json.Unmarshal(oldValue, &pullRequests); err != nil {
+				return nil, err
+			}
+		}
+
+		update(pullRequests)
+		if len(pullRequests) == 0 {
+			return nil, nil
+		}
+
+		return pullRequests, nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update open pull requests: %w", err)
+	}
+
+	return nil
+}
+
+// trackPullRequest records that an event was seen for the open pull request.
+func (p *Plugin) trackPullRequest(repo *github.Repository, pullRequest *github.PullRequest) error {
+	if pullRequest.GetState() == "closed" {
+		return p.untrackPullRequest(repo, pullRequest)
+	}
+
+	var reviewers []string
+	for _, reviewer := range pullRequest.RequestedReviewers {
+		reviewers = append(reviewers, reviewer.GetLogin())
+	}
+
+	return p.updateTrackedPullRequests(func(pullRequests map[string]trackedPullRequest) {
+		pullRequests[trackedPullRequestKey(repo, pullRequest)] = trackedPullRequest{
+			Event:        newPullRequestRoutedEvent(githubevents.PullRequestEventOpenedAction, repo, pullRequest),
+			RepoID:       repo.GetID(),
+			OwnerName:    repo.GetOwner().GetName(),
+			Number:       pullRequest.GetNumber(),
+			Title:        pullRequest.GetTitle(),
+			URL:          pullRequest.GetHTMLURL(),
+			Author:       pullRequest.GetUser().GetLogin(),
+			Reviewers:    reviewers,
+			Draft:        pullRequest.GetDraft(),
+			LastActivity: time.Now(),
+		}
+	})
+}
+
+func (p *Plugin) untrackPullRequest(repo *github.Repository, pullRequest *github.PullRequest) error {
+	return p.updateTrackedPullRequests(func(pullRequests map[string]trackedPullRequest) {
+		delete(pullRequests, trackedPullRequestKey(repo, pullRequest))
+	})
+}
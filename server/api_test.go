@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"net/http"
+	"slices"
+	"sync"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+	"github.com/mattermost/mattermost/server/public/pluginapi"
+)
+
+// testAPI is an in-memory implementation of the parts of the plugin API used by the tests. Calling
+// any other method panics through the nil embedded interface.
+type testAPI struct {
+	plugin.API
+
+	mu       sync.Mutex
+	kv       map[string][]byte
+	posts    map[string]*model.Post
+	channels map[string]*model.Channel
+	users    map[string]*model.User
+	config   *model.Config
+
+	// failChannels makes creating posts in the channels fail.
+	failChannels map[string]bool
+}
+
+func newTestPlugin() (*Plugin, *testAPI) {
+	api := &testAPI{kv: map[string][]byte{}, posts: map[string]*model.Post{}, channels: map[string]*model.Channel{}, users: map[string]*model.User{}}
+	botUserId := "bot"
+	p := &Plugin{botUserId: &botUserId}
+	p.API = api
+	p.client = pluginapi.NewClient(api, nil)
+	return p, api
+}
+
+func notFound(where string) *model.AppError {
+	return model.NewAppError(where, "not_found", nil, "", http.StatusNotFound)
+}
+
+func (a *testAPI) KVGet(key string) ([]byte, *model.AppError) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.kv[key], nil
+}
+
+func (a *testAPI) KVSetWithOptions(key string, value []byte, options model.PluginKVSetOptions) (bool, *model.AppError) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if options.Atomic && !bytes.Equal(a.kv[key], options.OldValue) {
+		return false, nil
+	}
+	if value == nil {
+		delete(a.kv, key)
+	} else {
+		a.kv[key] = value
+	}
+	return true, nil
+}
+
+// addChannel makes the channel with the given ID known as team/name.
+func (a *testAPI) addChannel(teamName, channelName, channelID string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.channels[teamName+"/"+channelName] = &model.Channel{Id: channelID, Name: channelName}
+}
+
+func (a *testAPI) GetChannelByNameForTeamName(teamName, channelName string, includeDeleted bool) (*model.Channel, *model.AppError) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	channel, ok := a.channels[teamName+"/"+channelName]
+	if !ok {
+		return nil, notFound("GetChannelByNameForTeamName")
+	}
+	return channel, nil
+}
+
+func (a *testAPI) addUser(userID, username string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.users[userID] = &model.User{Id: userID, Username: username}
+}
+
+func (a *testAPI) GetUser(userID string) (*model.User, *model.AppError) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	user, ok := a.users[userID]
+	if !ok {
+		return nil, notFound("GetUser")
+	}
+	return user, nil
+}
+
+func (a *testAPI) GetUserByUsername(username string) (*model.User, *model.AppError) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, user := range a.users {
+		if user.Username == username {
+			return user, nil
+		}
+	}
+	return nil, notFound("GetUserByUsername")
+}
+
+func (a *testAPI) CreatePost(post *model.Post) (*model.Post, *model.AppError) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.failChannels[post.ChannelId] {
+		return nil, model.NewAppError("CreatePost", "failed", nil, "", http.StatusInternalServerError)
+	}
+
+	created := post.Clone()
+	created.Id = model.NewId()
+	created.CreateAt = int64(len(a.posts) + 1)
+	a.posts[created.Id] = created
+	return created.Clone(), nil
+}
+
+func (a *testAPI) GetPost(postID string) (*model.Post, *model.AppError) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	post, ok := a.posts[postID]
+	if !ok {
+		return nil, notFound("GetPost")
+	}
+	return post.Clone(), nil
+}
+
+func (a *testAPI) UpdatePost(post *model.Post) (*model.Post, *model.AppError) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if _, ok := a.posts[post.Id]; !ok {
+		return nil, notFound("UpdatePost")
+	}
+	a.posts[post.Id] = post.Clone()
+	return post.Clone(), nil
+}
+
+// GetPostsForChannel lists the posts of the channel newest first.
+func (a *testAPI) GetPostsForChannel(channelID string, page, perPage int) (*model.PostList, *model.AppError) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var posts []*model.Post
+	for _, post := range a.posts {
+		if post.ChannelId == channelID {
+			posts = append(posts, post)
+		}
+	}
+	slices.SortFunc(posts, func(a, b *model.Post) int { return cmp.Compare(b.CreateAt, a.CreateAt) })
+
+	postList := model.NewPostList()
+	for i := page * perPage; i < len(posts) && i < (page+1)*perPage; i++ {
+		postList.AddPost(posts[i].Clone())
+		postList.AddOrder(posts[i].Id)
+	}
+	return postList, nil
+}
+
+// replies returns the messages of the replies to the post.
+func (a *testAPI) replies(postID string) []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var messages []string
+	for _, post := range a.posts {
+		if post.RootId == postID {
+			messages = append(messages, post.Message)
+		}
+	}
+	return messages
+}
+
+func (a *testAPI) post(postID string) *model.Post {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.posts[postID]
+}
+
+func (a *testAPI) GetPluginID() string {
+	return "org.holochain.mm-plugin"
+}
+
+func (a *testAPI) GetConfig() *model.Config {
+	return a.config
+}
+
+func (a *testAPI) LogDebug(string, ...any) {}
+func (a *testAPI) LogInfo(string, ...any)  {}
+func (a *testAPI) LogWarn(string, ...any)  {}
+func (a *testAPI) LogError(string, ...any) {}
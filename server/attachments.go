@@ -0,0 +1,211 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/google/go-github/v76/github"
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+// Attachment colours follow the colours that GitHub uses for each state.
+const (
+	colorOpen       = "#1f883d"
+	colorDraft      = "#59636e"
+	colorMerged     = "#8250df"
+	colorClosed     = "#d1242f"
+	colorNotPlanned = "#59636e"
+	colorRelease    = "#0969da"
+	colorPreRelease = "#bf8700"
+)
+
+// closingKeywordPattern finds the issues that a pull request closes, e.g. "Fixes #12" or
+// "closes holochain/lair#3".
+var closingKeywordPattern = regexp.MustCompile(`(?i)\b(?:close[sd]?|fix(?:e[sd])?|resolve[sd]?):?\s+([\w.-]+/[\w.-]+)?#(\d+)\b`)
+
+// postContent is the content of the bot's post about a GitHub object. The message always holds
+// the object's tag, so that posts stay searchable by it when the details are in attachments.
+type postContent struct {
+	Message     string
+	Attachments []*model.SlackAttachment
+}
+
+// textContent is a post without attachments.
+func textContent(message string) postContent {
+	return postContent{Message: message}
+}
+
+// apply replaces the post's message and attachments with the content.
+func (c postContent) apply(post *model.Post) {
+	post.Message = c.Message
+	if len(c.Attachments) > 0 {
+		model.ParseSlackAttachment(post, c.Attachments)
+		return
+	}
+
+	post.DelProp(model.PostPropsAttachments)
+	if post.Type == model.PostTypeSlackAttachment {
+		post.Type = model.PostTypeDefault
+	}
+}
+
+// matches reports whether the post already has the content.
+func (c postContent) matches(post *model.Post) bool {
+	expected := &model.Post{}
+	c.apply(expected)
+
+	return post.Message == expected.Message && post.AttachmentsEqual(expected)
+}
+
+// authorAttachment returns an attachment with the GitHub user as its author.
+func authorAttachment(user *github.User) *model.SlackAttachment {
+	return &model.SlackAttachment{
+		AuthorName: user.GetLogin(),
+		AuthorLink: user.GetHTMLURL(),
+		AuthorIcon: user.GetAvatarURL(),
+	}
+}
+
+// issueAttachment renders the issue's current state. Authors and assignees are mentioned if they
+// are known in Mattermost.
+func (p *Plugin) issueAttachment(issue *github.Issue) *model.SlackAttachment {
+	attachment := authorAttachment(issue.GetUser())
+	attachment.Title = issue.GetTitle()
+	attachment.TitleLink = issue.GetHTMLURL()
+	attachment.Color = colorOpen
+
+	if issue.GetState() == "closed" {
+		if issue.GetStateReason() == "not_planned" {
+			attachment.Title = fmt.Sprintf(":no_entry_sign: ~~%s~~", issue.GetTitle())
+			attachment.Color = colorNotPlanned
+		} else {
+			attachment.Title = fmt.Sprintf(":white_check_mark: Closed: %s", issue.GetTitle())
+			attachment.Color = colorMerged
+		}
+	}
+
+	attachment.Fallback = fmt.Sprintf("%s %s", attachment.Title, issue.GetHTMLURL())
+	attachment.Text = fmt.Sprintf("Opened by %s", p.mention(issue.GetUser().GetLogin()))
+
+	if labels := labelList(issue.Labels); labels != "" {
+		attachment.Fields = append(attachment.Fields, &model.SlackAttachmentField{Title: "Labels", Value: labels, Short: true})
+	}
+
+	if len(issue.Assignees) > 0 {
+		assignees := make([]string, 0, len(issue.Assignees))
+		for _, assignee := range issue.Assignees {
+			assignees = append(assignees, p.mention(assignee.GetLogin()))
+		}
+		attachment.Fields = append(attachment.Fields, &model.SlackAttachmentField{Title: "Assignees", Value: strings.Join(assignees, ", "), Short: true})
+	}
+
+	if milestone := issue.GetMilestone(); milestone.GetTitle() != "" {
+		attachment.Fields = append(attachment.Fields, &model.SlackAttachmentField{
+			Title: "Milestone",
+			Value: fmt.Sprintf("[%s](%s)", milestone.GetTitle(), milestone.GetHTMLURL()),
+			Short: true,
+		})
+	}
+
+	return attachment
+}
+
+// pullRequestAttachment renders the pull request's current state.
+func (p *Plugin) pullRequestAttachment(repo *github.Repository, pullRequest *github.PullRequest) *model.SlackAttachment {
+	attachment := authorAttachment(pullRequest.GetUser())
+	attachment.Title = pullRequest.GetTitle()
+	attachment.TitleLink = pullRequest.GetHTMLURL()
+	attachment.Color = colorOpen
+
+	switch {
+	case pullRequest.GetMerged():
+		attachment.Title = fmt.Sprintf(":white_check_mark: Merged: %s", pullRequest.GetTitle())
+		attachment.Color = colorMerged
+	case pullRequest.GetState() == "closed":
+		attachment.Title = fmt.Sprintf(":no_entry_sign: ~~%s~~", pullRequest.GetTitle())
+		attachment.Color = colorClosed
+	case pullRequest.GetDraft():
+		attachment.Title = fmt.Sprintf(":construction: Draft: %s", pullRequest.GetTitle())
+		attachment.Color = colorDraft
+	}
+
+	attachment.Fallback = fmt.Sprintf("%s %s", attachment.Title, pullRequest.GetHTMLURL())
+	attachment.Text = fmt.Sprintf("Opened by %s", p.mention(pullRequest.GetUser().GetLogin()))
+
+	attachment.Fields = append(attachment.Fields, &model.SlackAttachmentField{
+		Title: "Branches",
+		Value: fmt.Sprintf("`%s` ← `%s`", pullRequest.GetBase().GetRef(), pullRequest.GetHead().GetLabel()),
+		Short: true,
+	})
+
+	// Pull requests listed by the REST API, e.g. during reconciliation, come without their changes
+	if pullRequest.Additions != nil {
+		attachment.Fields = append(attachment.Fields, &model.SlackAttachmentField{
+			Title: "Changes",
+			Value: fmt.Sprintf("+%d −%d in %d files", pullRequest.GetAdditions(), pullRequest.GetDeletions(), pullRequest.GetChangedFiles()),
+			Short: true,
+		})
+	}
+
+	if labels := labelList(pullRequest.Labels); labels != "" {
+		attachment.Fields = append(attachment.Fields, &model.SlackAttachmentField{Title: "Labels", Value: labels, Short: true})
+	}
+
+	if issues := linkedIssues(repo.GetFullName(), pullRequest.GetBody()); len(issues) > 0 {
+		attachment.Fields = append(attachment.Fields, &model.SlackAttachmentField{Title: "Linked issues", Value: strings.Join(issues, ", "), Short: true})
+	}
+
+	return attachment
+}
+
+// releaseAttachment renders the release, with its details as fields and up to notesLength
+// characters of its release notes as text.
+func releaseAttachment(repo *github.Repository, release *github.RepositoryRelease, isPreRelease bool, notesLength int) *model.SlackAttachment {
+	attachment := authorAttachment(release.GetAuthor())
+	attachment.Title = release.GetName()
+	if attachment.Title == "" {
+		attachment.Title = release.GetTagName()
+	}
+	attachment.TitleLink = release.GetHTMLURL()
+	attachment.Text = releaseNotes(repo.GetFullName(), release.GetBody(), release.GetHTMLURL(), notesLength)
+	attachment.Fallback = fmt.Sprintf("%s %s %s", repo.GetName(), attachment.Title, release.GetHTMLURL())
+
+	preRelease := "No"
+	attachment.Color = colorRelease
+	if isPreRelease {
+		preRelease = "Yes"
+		attachment.Color = colorPreRelease
+	}
+
+	attachment.Fields = []*model.SlackAttachmentField{
+		{Title: "Repository", Value: fmt.Sprintf("[%s](%s)", repo.GetName(), repo.GetHTMLURL()), Short: true},
+		{Title: "Tag", Value: fmt.Sprintf("`%s`", release.GetTagName()), Short: true},
+		{Title: "Pre-release", Value: preRelease, Short: true},
+	}
+
+	return attachment
+}
+
+// linkedIssues returns links to the issues that the pull request body says it closes.
+func linkedIssues(repository, body string) []string {
+	var issues []string
+	for _, match := range closingKeywordPattern.FindAllStringSubmatch(body, -1) {
+		issueRepository, number := match[1], match[2]
+
+		reference := "#" + number
+		if issueRepository != "" && !strings.EqualFold(issueRepository, repository) {
+			reference = issueRepository + reference
+		} else {
+			issueRepository = repository
+		}
+
+		link := fmt.Sprintf("[%s](https://github.com/%s/issues/%s)", reference, issueRepository, number)
+		if !slices.Contains(issues, link) {
+			issues = append(issues, link)
+		}
+	}
+
+	return issues
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/go-github/v76/github"
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+func TestLinkedIssues(t *testing.T) {
+	for name, tc := range map[string]struct {
+		body     string
+		expected []string
+	}{
+		"no issues": {
+			body: "Refactors the parser, see #12",
+		},
+		"closing keywords": {
+			body: "Fixes #12\nThis also closes: holochain/lair#3 and resolves #12.",
+			expected: []string{
+				"[#12](https://github.com/holochain/holochain/issues/12)",
+				"[holochain/lair#3](https://github.com/holochain/lair/issues/3)",
+			},
+		},
+		"same repository": {
+			body:     "Resolved Holochain/Holochain#5",
+			expected: []string{"[#5](https://github.com/holochain/holochain/issues/5)"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if issues := linkedIssues("holochain/holochain", tc.body); !reflect.DeepEqual(issues, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, issues)
+			}
+		})
+	}
+}
+
+func TestReleaseAttachment(t *testing.T) {
+	repo := &github.Repository{Name: github.Ptr("holochain"), HTMLURL: github.Ptr("https://github.com/holochain/holochain")}
+	release := &github.RepositoryRelease{
+		TagName: github.Ptr("v0.4.0-rc.1"),
+		HTMLURL: github.Ptr("https://github.com/holochain/holochain/releases/tag/v0.4.0-rc.1"),
+		Author:  &github.User{Login: github.Ptr("octocat")},
+	}
+
+	attachment := releaseAttachment(repo, release, true, defaultReleaseNotesLength)
+
+	if attachment.Title != "v0.4.0-rc.1" {
+		t.Errorf("expected the tag as title, got %q", attachment.Title)
+	}
+	if attachment.Color != colorPreRelease {
+		t.Errorf("expected colour %s, got %s", colorPreRelease, attachment.Color)
+	}
+
+	expectedFields := []*model.SlackAttachmentField{
+		{Title: "Repository", Value: "[holochain](https://github.com/holochain/holochain)", Short: true},
+		{Title: "Tag", Value: "`v0.4.0-rc.1`", Short: true},
+		{Title: "Pre-release", Value: "Yes", Short: true},
+	}
+	if !reflect.DeepEqual(attachment.Fields, expectedFields) {
+		t.Errorf("expected fields %v, got %v", expectedFields, attachment.Fields)
+	}
+}
+
+func TestPostContentMatches(t *testing.T) {
+	attachment := &model.SlackAttachment{Title: "Fix crash", Color: colorOpen}
+	content := postContent{Message: "#holochain.holochain.1", Attachments: []*model.SlackAttachment{attachment}}
+
+	post := &model.Post{}
+	content.apply(post)
+	if !content.matches(post) {
+		t.Error("expected the post to match its content")
+	}
+
+	changed := postContent{Message: content.Message, Attachments: []*model.SlackAttachment{{Title: "Fix crash", Color: colorMerged}}}
+	if changed.matches(post) {
+		t.Error("expected the post not to match changed attachments")
+	}
+
+	text := textContent("#holochain.holochain.1")
+	if text.matches(post) {
+		t.Error("expected the post not to match content without attachments")
+	}
+
+	text.apply(post)
+	if !text.matches(post) || len(post.Attachments()) != 0 || post.Type != model.PostTypeDefault {
+		t.Errorf("expected the attachments to be removed, got %v", post.Attachments())
+	}
+}
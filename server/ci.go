@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/cbrgm/githubevents/v2/githubevents"
+	"github.com/google/go-github/v76/github"
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/pluginapi"
+)
+
+const ciFailureKeyPrefix = "ci_failure_"
+
+// defaultCIBranches are watched for failures if no branches are configured.
+var defaultCIBranches = []string{"main", "develop"}
+
+// parseCITarget reads the CI channel from the configuration, or returns nil if CI alerts are
+// disabled.
+func parseCITarget(config *Configuration) (*routeTarget, error) {
+	team, channel := splitChannelName(config.CIChannelName)
+	if channel == "" {
+		return nil, nil
+	}
+	if team == "" {
+		team = strings.TrimSpace(config.MattermostTeamName)
+	}
+	if team == "" {
+		return nil, errors.New("team is not set and there is no default team")
+	}
+
+	return &routeTarget{Team: team, Channel: channel}, nil
+}
+
+// parseBranchList splits a list of branch name globs separated by commas or whitespace.
+func parseBranchList(list string) ([]string, error) {
+	branches := strings.FieldsFunc(list, func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	})
+	if len(branches) == 0 {
+		return defaultCIBranches, nil
+	}
+
+	for _, pattern := range branches {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid branch pattern %q: %w", pattern, err)
+		}
+	}
+
+	return branches, nil
+}
+
+// isCIBranch reports whether failures on the branch are alerted.
+func isCIBranch(branches []string, branch string) bool {
+	return slices.ContainsFunc(branches, func(pattern string) bool {
+		matched, _ := path.Match(pattern, branch)
+		return matched
+	})
+}
+
+// ciRun is a completed workflow run or check suite on a branch.
+type ciRun struct {
+	Repository *github.Repository
+	// Kind and ID identify what ran, so that a success can be matched with earlier failures of
+	// the same workflow or check suite app.
+	Kind       string
+	ID         int64
+	Name       string
+	Branch     string
+	SHA        string
+	Message    string
+	Actor      string
+	URL        string
+	Conclusion string
+}
+
+func (r *ciRun) key() string {
+	// Branch names can be longer than KV keys allow
+	hash := sha1.Sum([]byte(r.Branch))
+
+	return fmt.Sprintf("%s%d_%s_%d_%s", ciFailureKeyPrefix, r.Repository.GetID(), r.Kind, r.ID, hex.EncodeToString(hash[:]))
+}
+
+func (r *ciRun) failed() bool {
+	switch r.Conclusion {
+	case "failure", "timed_out", "startup_failure":
+		return true
+	default:
+		return false
+	}
+}
+
+// registerCIHandlers alerts the CI channel when a workflow run or check suite fails on one of
+// the watched branches, and replies to the alert once the same workflow succeeds again.
+func (p *Plugin) registerCIHandlers(eventHandler *githubevents.EventHandler, config *Configuration) {
+	if config.ciTarget == nil {
+		return
+	}
+
+	eventHandler.OnWorkflowRunEventCompleted(
+		func(ctx context.Context, deliveryID string, eventName string, event *github.WorkflowRunEvent) error {
+			repo := event.GetRepo()
+			run := event.GetWorkflowRun()
+
+			// Runs for pull requests report the pull request's branch, which is not broken by a failure
+			if run.GetEvent() != "push" {
+				return nil
+			}
+
+			return p.handleCIRun(&ciRun{
+				Repository: repo,
+				Kind:       "workflow",
+				ID:         run.GetWorkflowID(),
+				Name:       run.GetName(),
+				Branch:     run.GetHeadBranch(),
+				SHA:        run.GetHeadSHA(),
+				Message:    run.GetHeadCommit().GetMessage(),
+				Actor:      run.GetActor().GetLogin(),
+				URL:        run.GetHTMLURL(),
+				Conclusion: run.GetConclusion(),
+			})
+		})
+
+	eventHandler.OnCheckSuiteEventCompleted(
+		func(ctx context.Context, deliveryID string, eventName string, event *github.CheckSuiteEvent) error {
+			repo := event.GetRepo()
+			checkSuite := event.GetCheckSuite()
+
+			// GitHub Actions are reported in more detail by their workflow runs, and suites for pull
+			// requests are replied to in the pull request's thread
+			if checkSuite.GetApp().GetSlug() == "github-actions" || len(checkSuite.PullRequests) > 0 {
+				return nil
+			}
+
+			sha := checkSuite.GetHeadSHA()
+
+			return p.handleCIRun(&ciRun{
+				Repository: repo,
+				Kind:       "app",
+				ID:         checkSuite.GetApp().GetID(),
+				Name:       checkSuite.GetApp().GetName(),
+				Branch:     checkSuite.GetHeadBranch(),
+				SHA:        sha,
+				Message:    checkSuite.GetHeadCommit().GetMessage(),
+				Actor:      event.GetSender().GetLogin(),
+				URL:        fmt.Sprintf("%s/commit/%s/checks", repo.GetHTMLURL(), sha),
+				Conclusion: checkSuite.GetConclusion(),
+			})
+		})
+}
+
+// handleCIRun posts an alert for a failed run, or a reply to the earlier alert once the
+// workflow succeeds again. Later failures while the workflow is still broken are added to the
+// thread of the first alert.
+func (p *Plugin) handleCIRun(run *ciRun) error {
+	config := p.getConfiguration()
+	if config.ciTarget == nil || !isCIBranch(config.ciBranches, run.Branch) {
+		return nil
+	}
+
+	var failurePostID string
+	if err := p.client.KV.Get(run.key(), &failurePostID); err != nil {
+		return fmt.Errorf("failed to get CI failure for %s: %w", run.Name, err)
+	}
+
+	switch {
+	case run.failed() && failurePostID == "":
+		post, err := p.postCIMessage(config.ciTarget, "", ciFailureMessage(run, p.mention(run.Actor)))
+		if err != nil {
+			return err
+		}
+
+		if _, err = p.client.KV.Set(run.key(), post.Id); err != nil {
+			return fmt.Errorf("failed to store CI failure for %s: %w", run.Name, err)
+		}
+	case run.failed():
+		message := fmt.Sprintf(":x: Still failing at [%s](%s) by %s", shortSHA(run.SHA), run.URL, p.mention(run.Actor))
+		if _, err := p.postCIMessage(config.ciTarget, failurePostID, message); err != nil {
+			return err
+		}
+	case run.Conclusion == "success" && failurePostID != "":
+		message := fmt.Sprintf(":white_check_mark: Fixed by [%s](%s) from %s", shortSHA(run.SHA), run.URL, p.mention(run.Actor))
+		if _, err := p.postCIMessage(config.ciTarget, failurePostID, message); err != nil {
+			return err
+		}
+
+		if err := p.client.KV.Delete(run.key()); err != nil {
+			return fmt.Errorf("failed to remove CI failure for %s: %w", run.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// postCIMessage posts to the CI channel, as a reply if rootID is set. Replies to alerts that
+// were deleted are posted as new posts instead.
+func (p *Plugin) postCIMessage(target *routeTarget, rootID, message string) (*model.Post, error) {
+	botUserId := p.botUserId
+	if botUserId == nil {
+		return nil, fmt.Errorf("bot user ID is nil")
+	}
+
+	channel, err := p.getChannel(target.Team, target.Channel)
+	if err != nil {
+		return nil, err
+	}
+
+	if rootID != "" {
+		root, err := p.client.Post.GetPost(rootID)
+		if err != nil && !errors.Is(err, pluginapi.ErrNotFound) {
+			return nil, fmt.Errorf("failed to get CI failure post %s: %w", rootID, err)
+		}
+		if root == nil || root.DeleteAt != 0 || root.ChannelId != channel.Id {
+			rootID = ""
+		}
+	}
+
+	post := &model.Post{
+		UserId:    *botUserId,
+		ChannelId: channel.Id,
+		RootId:    rootID,
+		Message:   message,
+	}
+	if err = p.client.Post.CreatePost(post); err != nil {
+		return nil, fmt.Errorf("failed to create post in channel %s: %w", channel.Name, err)
+	}
+
+	return post, nil
+}
+
+func ciFailureMessage(run *ciRun, actor string) string {
+	commit, _, _ := strings.Cut(strings.TrimSpace(run.Message), "\n")
+
+	return fmt.Sprintf(":x: **%s** failed on `%s` in [%s](%s)\nCommit: [%s](%s/commit/%s) %s\nActor: %s\nRun: %s",
+		run.Name, run.Branch,
+		run.Repository.GetFullName(), run.Repository.GetHTMLURL(),
+		shortSHA(run.SHA), run.Repository.GetHTMLURL(), run.SHA, commit,
+		actor,
+		run.URL)
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/cbrgm/githubevents/v2/githubevents"
+	"github.com/google/go-github/v76/github"
+)
+
+func TestParseCITarget(t *testing.T) {
+	for name, tc := range map[string]struct {
+		config         Configuration
+		expectedTarget *routeTarget
+		expectedErr    bool
+	}{
+		"disabled": {
+			config:         Configuration{MattermostTeamName: "core"},
+			expectedTarget: nil,
+		},
+		"default team": {
+			config:         Configuration{MattermostTeamName: "core", CIChannelName: "ci"},
+			expectedTarget: &routeTarget{Team: "core", Channel: "ci"},
+		},
+		"team/channel notation": {
+			config:         Configuration{MattermostTeamName: "core", CIChannelName: "tooling/ci"},
+			expectedTarget: &routeTarget{Team: "tooling", Channel: "ci"},
+		},
+		"no team": {
+			config:      Configuration{CIChannelName: "ci"},
+			expectedErr: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			target, err := parseCITarget(&tc.config)
+			if tc.expectedErr {
+				if err == nil {
+					t.Errorf("expected an error, got target %v", target)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(target, tc.expectedTarget) {
+				t.Errorf("expected target %v, got %v", tc.expectedTarget, target)
+			}
+		})
+	}
+}
+
+func TestIsCIBranch(t *testing.T) {
+	branches, err := parseBranchList("main, develop\nrelease/*")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for name, tc := range map[string]struct {
+		branch   string
+		expected bool
+	}{
+		"exact match":                      {branch: "main", expected: true},
+		"glob":                             {branch: "release/0.4", expected: true},
+		"other branch":                     {branch: "feature/main", expected: false},
+		"glob does not match nested names": {branch: "release/0.4/fix", expected: false},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if actual := isCIBranch(branches, tc.branch); actual != tc.expected {
+				t.Errorf("expected %v for branch %s, got %v", tc.expected, tc.branch, actual)
+			}
+		})
+	}
+}
+
+func TestCIHandlers(t *testing.T) {
+	repo := &github.Repository{ID: github.Ptr(int64(1)), HTMLURL: github.Ptr("https://github.com/holochain/holochain")}
+	workflowRun := func(event string) *github.WorkflowRunEvent {
+		return &github.WorkflowRunEvent{
+			Action: github.Ptr("completed"),
+			Repo:   repo,
+			WorkflowRun: &github.WorkflowRun{
+				Event:      github.Ptr(event),
+				HeadBranch: github.Ptr("main"),
+				Conclusion: github.Ptr("failure"),
+			},
+		}
+	}
+	checkSuite := func(app string, pullRequests []*github.PullRequest) *github.CheckSuiteEvent {
+		return &github.CheckSuiteEvent{
+			Action: github.Ptr("completed"),
+			Repo:   repo,
+			CheckSuite: &github.CheckSuite{
+				App:          &github.App{Slug: github.Ptr(app)},
+				HeadBranch:   github.Ptr("main"),
+				Conclusion:   github.Ptr("failure"),
+				PullRequests: pullRequests,
+			},
+		}
+	}
+
+	for name, tc := range map[string]struct {
+		eventName string
+		event     any
+		expected  bool
+	}{
+		"workflow run for a push":         {eventName: "workflow_run", event: workflowRun("push"), expected: true},
+		"workflow run for a pull request": {eventName: "workflow_run", event: workflowRun("pull_request")},
+		"scheduled workflow run":          {eventName: "workflow_run", event: workflowRun("schedule")},
+		"check suite for a push":          {eventName: "check_suite", event: checkSuite("buildkite", nil), expected: true},
+		"check suite for a pull request":  {eventName: "check_suite", event: checkSuite("buildkite", []*github.PullRequest{{Number: github.Ptr(1)}})},
+		"github actions check suite":      {eventName: "check_suite", event: checkSuite("github-actions", nil)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			p, api := newTestPlugin()
+			api.addChannel("core", "ci", "ci")
+			config := &Configuration{ciTarget: &routeTarget{Team: "core", Channel: "ci"}, ciBranches: []string{"main"}}
+			p.setConfiguration(config)
+
+			eventHandler := githubevents.New("")
+			p.registerCIHandlers(eventHandler, config)
+			if err := eventHandler.HandleEvent(context.Background(), "", tc.eventName, tc.event); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if alerted := len(api.posts) > 0; alerted != tc.expected {
+				t.Errorf("expected alert %v, got %v", tc.expected, alerted)
+			}
+		})
+	}
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+)
+
+const (
+	githubCommandTrigger = "github"
+	hcCommandTrigger     = "hc"
+)
+
+const githubCommandHelp = "* `/github link` - Link your GitHub account, so that you are mentioned in posts about your activity\n" +
+	"* `/github unlink` - Unlink your GitHub account\n" +
+	"* `/github status` - Show which GitHub account is linked"
+
+const hcCommandHelp = "* `/hc subscribe <owner/repo> [events] [--labels label,...]` - Post events of a repository to this channel. " +
+	"Events are a comma separated list of `issues`, `pull_request` and `release`, all of them by default\n" +
+	"* `/hc unsubscribe <owner/repo>` - Stop posting events of a repository to this channel\n" +
+	"* `/hc list` - List the repositories this channel is subscribed to\n" +
+	"* `/hc digest now` - Post a digest of the recent activity to this channel"
+
+// registerCommands registers the plugin's slash commands with the server.
+func (p *Plugin) registerCommands() error {
+	autocomplete := model.NewAutocompleteData(githubCommandTrigger, "[command]", "Available commands: link, unlink, status")
+	autocomplete.AddCommand(model.NewAutocompleteData("link", "", "Link your GitHub account"))
+	autocomplete.AddCommand(model.NewAutocompleteData("unlink", "", "Unlink your GitHub account"))
+	autocomplete.AddCommand(model.NewAutocompleteData("status", "", "Show which GitHub account is linked"))
+
+	err := p.client.SlashCommand.Register(&model.Command{
+		Trigger:          githubCommandTrigger,
+		DisplayName:      "GitHub",
+		Description:      "Link your GitHub account to Mattermost",
+		AutoComplete:     true,
+		AutoCompleteDesc: "Available commands: link, unlink, status",
+		AutoCompleteHint: "[command]",
+		AutocompleteData: autocomplete,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to register /%s command: %w", githubCommandTrigger, err)
+	}
+
+	autocomplete = model.NewAutocompleteData(hcCommandTrigger, "[command]", "Available commands: subscribe, unsubscribe, list, digest")
+	subscribe := model.NewAutocompleteData("subscribe", "<owner/repo> [events] [--labels label,...]", "Post events of a repository to this channel")
+	subscribe.AddTextArgument("Repository, e.g. holochain/holochain", "<owner/repo>", "")
+	autocomplete.AddCommand(subscribe)
+	unsubscribe := model.NewAutocompleteData("unsubscribe", "<owner/repo>", "Stop posting events of a repository to this channel")
+	unsubscribe.AddTextArgument("Repository, e.g. holochain/holochain", "<owner/repo>", "")
+	autocomplete.AddCommand(unsubscribe)
+	autocomplete.AddCommand(model.NewAutocompleteData("list", "", "List the repositories this channel is subscribed to"))
+	digest := model.NewAutocompleteData("digest", "now", "Post a digest of the recent activity to this channel")
+	digest.AddCommand(model.NewAutocompleteData("now", "", "Post a digest of the recent activity to this channel"))
+	autocomplete.AddCommand(digest)
+
+	err = p.client.SlashCommand.Register(&model.Command{
+		Trigger:          hcCommandTrigger,
+		DisplayName:      "Holochain",
+		Description:      "Manage the GitHub repositories posted to a channel",
+		AutoComplete:     true,
+		AutoCompleteDesc: "Available commands: subscribe, unsubscribe, list, digest",
+		AutoCompleteHint: "[command]",
+		AutocompleteData: autocomplete,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to register /%s command: %w", hcCommandTrigger, err)
+	}
+
+	return nil
+}
+
+// ExecuteCommand handles the plugin's slash commands. Responses are only shown to the user that
+// ran the command.
+func (p *Plugin) ExecuteCommand(c *plugin.Context, args *model.CommandArgs) (*model.CommandResponse, *model.AppError) {
+	fields := strings.Fields(args.Command)
+	if len(fields) == 0 {
+		return &model.CommandResponse{}, nil
+	}
+
+	var message string
+	switch strings.TrimPrefix(fields[0], "/") {
+	case githubCommandTrigger:
+		message = p.executeGitHubCommand(args, fields[1:])
+	case hcCommandTrigger:
+		message = p.executeHCCommand(args, fields[1:])
+	default:
+		message = fmt.Sprintf("Unknown command %s", fields[0])
+	}
+
+	return &model.CommandResponse{
+		ResponseType: model.CommandResponseTypeEphemeral,
+		Text:         message,
+	}, nil
+}
+
+func (p *Plugin) executeGitHubCommand(args *model.CommandArgs, fields []string) string {
+	if len(fields) == 0 {
+		return githubCommandHelp
+	}
+
+	switch fields[0] {
+	case "link":
+		code, err := p.startAccountLink(args.UserId)
+		if err != nil {
+			p.client.Log.Warn("Failed to start account link", "user_id", args.UserId, "error", err.Error())
+			return fmt.Sprintf("Linking your GitHub account failed: %s", err)
+		}
+
+		return fmt.Sprintf("Open %s and enter the code `%s` to link your GitHub account. The code expires in %d minutes, you will get a direct message once your account is linked.",
+			code.VerificationURI, code.UserCode, code.ExpiresIn/60)
+	case "unlink":
+		if err := p.unlinkAccounts(args.UserId); err != nil {
+			p.client.Log.Error("Failed to unlink account", "user_id", args.UserId, "error", err.Error())
+			return "Unlinking your GitHub account failed, please try again."
+		}
+
+		return "Your GitHub account is no longer linked."
+	case "status":
+		login, err := p.getLinkedLogin(args.UserId)
+		if err != nil {
+			p.client.Log.Error("Failed to get account link", "user_id", args.UserId, "error", err.Error())
+			return "Getting your linked GitHub account failed, please try again."
+		}
+		if login == "" {
+			return "No GitHub account is linked, use `/github link` to link one."
+		}
+
+		return fmt.Sprintf("Your Mattermost account is linked to GitHub account [%s](https://github.com/%s).", login, login)
+	default:
+		return githubCommandHelp
+	}
+}
+
+func (p *Plugin) executeHCCommand(args *model.CommandArgs, fields []string) string {
+	if len(fields) == 0 {
+		return hcCommandHelp
+	}
+
+	switch fields[0] {
+	case "subscribe", "unsubscribe":
+		channel, err := p.client.Channel.Get(args.ChannelId)
+		if err != nil {
+			p.client.Log.Error("Failed to get channel", "channel_id", args.ChannelId, "error", err.Error())
+			return "Getting this channel failed, please try again."
+		}
+		if !p.canManageSubscriptions(args.UserId, channel) {
+			return "Only channel admins can change the subscriptions of this channel."
+		}
+
+		if fields[0] == "subscribe" {
+			return p.executeSubscribe(args, channel, fields[1:])
+		}
+		return p.executeUnsubscribe(channel, fields[1:])
+	case "list":
+		subscriptions, err := p.getChannelSubscriptions(args.ChannelId)
+		if err != nil {
+			p.client.Log.Error("Failed to get subscriptions", "channel_id", args.ChannelId, "error", err.Error())
+			return "Getting the subscriptions of this channel failed, please try again."
+		}
+		if len(subscriptions) == 0 {
+			return "This channel is not subscribed to any repositories."
+		}
+
+		lines := []string{"This channel is subscribed to:"}
+		for _, sub := range subscriptions {
+			lines = append(lines, "* "+subscriptionDescription(sub))
+		}
+
+		return strings.Join(lines, "\n")
+	case "digest":
+		if len(fields) != 2 || fields[1] != "now" {
+			return hcCommandHelp
+		}
+
+		channel, err := p.client.Channel.Get(args.ChannelId)
+		if err != nil {
+			p.client.Log.Error("Failed to get channel", "channel_id", args.ChannelId, "error", err.Error())
+			return "Getting this channel failed, please try again."
+		}
+		if !p.canManageSubscriptions(args.UserId, channel) {
+			return "Only channel admins can post a digest to this channel."
+		}
+
+		if err = p.executeDigestNow(args.ChannelId); err != nil {
+			p.client.Log.Error("Failed to post digest", "channel_id", args.ChannelId, "error", err.Error())
+			return "Posting the digest failed, please try again."
+		}
+
+		return "Posted a digest of the recent activity."
+	default:
+		return hcCommandHelp
+	}
+}
+
+func (p *Plugin) executeSubscribe(args *model.CommandArgs, channel *model.Channel, fields []string) string {
+	sub, err := parseSubscription(fields)
+	if err != nil {
+		return fmt.Sprintf("%s\n\n%s", err, hcCommandHelp)
+	}
+	if !subscriptionAllowed(p.getConfiguration().subscriptionRepositories, sub.Repository) {
+		return fmt.Sprintf("Subscribing to %s is not allowed, ask a system admin to add it to the subscription repositories.", sub.Repository)
+	}
+	sub.ChannelID = channel.Id
+	sub.CreatorID = args.UserId
+
+	// The bot has to be in the team to post to the channel
+	team, err := p.client.Team.Get(channel.TeamId)
+	if err == nil && p.botUserId != nil {
+		err = p.ensureTeamMember(*p.botUserId, team.Name)
+	}
+	if err != nil {
+		p.client.Log.Error("Failed to join team of subscribed channel", "channel_id", channel.Id, "error", err.Error())
+		return "Subscribing failed, the bot could not join this team."
+	}
+
+	if err = p.subscribe(sub); err != nil {
+		p.client.Log.Error("Failed to subscribe", "channel_id", channel.Id, "repository", sub.Repository, "error", err.Error())
+		return "Subscribing failed, please try again."
+	}
+
+	return fmt.Sprintf("Subscribed to %s. Make sure the repository's webhook sends the events to this plugin.", subscriptionDescription(sub))
+}
+
+func (p *Plugin) executeUnsubscribe(channel *model.Channel, fields []string) string {
+	if len(fields) != 1 {
+		return hcCommandHelp
+	}
+
+	removed, err := p.unsubscribe(channel.Id, fields[0])
+	if err != nil {
+		p.client.Log.Error("Failed to unsubscribe", "channel_id", channel.Id, "repository", fields[0], "error", err.Error())
+		return "Unsubscribing failed, please try again."
+	}
+	if !removed {
+		return fmt.Sprintf("This channel is not subscribed to %s.", fields[0])
+	}
+
+	return fmt.Sprintf("Unsubscribed from %s.", fields[0])
+}
+
+// subscriptionDescription describes a subscription, e.g. "holochain/holochain (issues) with labels bug".
+func subscriptionDescription(sub subscription) string {
+	events := "all events"
+	if len(sub.Events) > 0 {
+		events = strings.Join(sub.Events, ", ")
+	}
+
+	description := fmt.Sprintf("%s (%s)", sub.Repository, events)
+	if len(sub.Labels) > 0 {
+		description += fmt.Sprintf(" with labels `%s`", strings.Join(sub.Labels, "`, `"))
+	}
+
+	return description
+}
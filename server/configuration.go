@@ -1,8 +1,14 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"net/netip"
 	"reflect"
+	"text/template"
 
+	"github.com/google/go-github/v76/github"
+	"github.com/mattermost/mattermost/server/public/model"
 	"github.com/pkg/errors"
 )
 
@@ -19,10 +25,79 @@ import (
 // copy appropriate for your types.
 type Configuration struct {
 	WebhookSecretToken                  string `json:"webhook_secret_token"`
+	PreviousWebhookSecrets              string `json:"previous_webhook_secrets"`
+	AllowUnsignedWebhooks               bool   `json:"allow_unsigned_webhooks"`
+	WebhookEndpoints                    string `json:"webhook_endpoints"`
+	RestrictWebhookSources              bool   `json:"restrict_webhook_sources"`
+	WebhookSourceRanges                 string `json:"webhook_source_ranges"`
+	RefreshWebhookSourceRanges          bool   `json:"refresh_webhook_source_ranges"`
+	TrustedProxies                      string `json:"trusted_proxies"`
 	MattermostTeamName                  string `json:"mattermost_team_name"`
 	MattermostIssueFeedChannelName      string `json:"mattermost_issue_feed_channel_name"`
 	MattermostPullRequestChannelName    string `json:"mattermost_pull_request_channel_name"`
 	MattermostReleaseCreatedChannelName string `json:"mattermost_release_created_channel_name"`
+	RoutingRules                        string `json:"routing_rules"`
+	SubscriptionRepositories            string `json:"subscription_repositories"`
+	ReconcileRepositories               string `json:"reconcile_repositories"`
+	ReconcileIntervalMinutes            int    `json:"reconcile_interval_minutes"`
+	ReconcileDryRun                     bool   `json:"reconcile_dry_run"`
+	GitHubToken                         string `json:"github_token"`
+	GitHubAppID                         int64  `json:"github_app_id"`
+	GitHubAppInstallationID             int64  `json:"github_app_installation_id"`
+	GitHubAppPrivateKey                 string `json:"github_app_private_key"`
+	IssueClosedMode                     string `json:"issue_closed_mode"`
+	IssueReopenedMode                   string `json:"issue_reopened_mode"`
+	IssueLabeledMode                    string `json:"issue_labeled_mode"`
+	IssueUnlabeledMode                  string `json:"issue_unlabeled_mode"`
+	IssueAssignedMode                   string `json:"issue_assigned_mode"`
+	IssueTransferredMode                string `json:"issue_transferred_mode"`
+	IssueMilestonedMode                 string `json:"issue_milestoned_mode"`
+	IssueDeletedMode                    string `json:"issue_deleted_mode"`
+	GitHubUserMapping                   string `json:"github_user_mapping"`
+	GitHubOAuthClientID                 string `json:"github_oauth_client_id"`
+	CIChannelName                       string `json:"ci_channel_name"`
+	CIBranches                          string `json:"ci_branches"`
+	DigestChannels                      string `json:"digest_channels"`
+	DigestSchedule                      string `json:"digest_schedule"`
+	DigestTime                          string `json:"digest_time"`
+	DigestWeekday                       string `json:"digest_weekday"`
+	DigestTimeZone                      string `json:"digest_time_zone"`
+	DigestStaleDays                     int    `json:"digest_stale_days"`
+	StaleReminderDays                   int    `json:"stale_reminder_days"`
+	StaleReminderDirectMessages         bool   `json:"stale_reminder_direct_messages"`
+	MessageTemplates                    string `json:"message_templates"`
+	ReleaseNotesLength                  int    `json:"release_notes_length"`
+
+	// webhookSecrets are parsed from WebhookSecretToken and PreviousWebhookSecrets.
+	webhookSecrets []webhookSecret
+	// webhookSourceRanges are parsed from WebhookSourceRanges.
+	webhookSourceRanges []netip.Prefix
+	// trustedProxies are parsed from TrustedProxies.
+	trustedProxies []netip.Prefix
+	// webhookEndpoints are parsed from WebhookEndpoints.
+	webhookEndpoints []webhookEndpoint
+	// routes are parsed from RoutingRules and the channel names above. They are not modified after
+	// parsing, so they can be shared between clones.
+	routes []route
+	// subscriptionRepositories are parsed from SubscriptionRepositories.
+	subscriptionRepositories []string
+	// reconcileRepositories are parsed from ReconcileRepositories.
+	reconcileRepositories []string
+	// github is the GitHub REST API client authenticated with the configured credentials. It
+	// caches GitHub App installation tokens, so it is shared between clones.
+	github *github.Client
+	// userMapping is parsed from GitHubUserMapping, mapping lower case GitHub logins to
+	// Mattermost usernames.
+	userMapping map[string]string
+	// ciTarget is parsed from CIChannelName, and is nil if CI alerts are disabled.
+	ciTarget *routeTarget
+	// ciBranches are parsed from CIBranches.
+	ciBranches []string
+	// digest is parsed from the digest settings.
+	digest *digestSchedule
+	// messageTemplates are parsed from MessageTemplates, keyed by event type or event type and
+	// action. Templates are safe to execute concurrently, so they are shared between clones.
+	messageTemplates map[string]*template.Template
 }
 
 // Clone shallow copies the Configuration. Your implementation may require a deep copy if
@@ -32,6 +107,20 @@ func (c *Configuration) Clone() *Configuration {
 	return &clone
 }
 
+// getConfiguration retrieves the active Configuration under lock, making it safe to use
+// concurrently. The active Configuration may change underneath the client of this method, but
+// the struct returned by this API call is considered immutable.
+func (p *Plugin) getConfiguration() *Configuration {
+	p.configurationLock.RLock()
+	defer p.configurationLock.RUnlock()
+
+	if p.configuration == nil {
+		return &Configuration{}
+	}
+
+	return p.configuration
+}
+
 // setConfiguration replaces the active Configuration under lock.
 //
 // Do not call setConfiguration while holding the configurationLock, as sync.Mutex is not
@@ -68,8 +157,165 @@ func (p *Plugin) OnConfigurationChange() error {
 		return errors.Wrap(err, "failed to load plugin Configuration")
 	}
 
+	webhookSecrets, err := parseWebhookSecrets(configuration.WebhookSecretToken, configuration.PreviousWebhookSecrets)
+	if err != nil {
+		return errors.Wrap(err, "failed to load webhook secrets")
+	}
+	configuration.webhookSecrets = webhookSecrets
+
+	routes, err := parseRoutes(configuration)
+	if err != nil {
+		return errors.Wrap(err, "failed to load routing rules")
+	}
+
+	webhookEndpoints, err := parseWebhookEndpoints(configuration)
+	if err != nil {
+		return errors.Wrap(err, "failed to load webhook endpoints")
+	}
+	configuration.webhookEndpoints = webhookEndpoints
+	configuration.routes = routes
+
+	subscriptionRepositories, err := parseSubscriptionRepositories(configuration.SubscriptionRepositories)
+	if err != nil {
+		return errors.Wrap(err, "failed to load subscription repositories")
+	}
+	configuration.subscriptionRepositories = subscriptionRepositories
+
+	reconcileRepositories, err := parseRepositoryList(configuration.ReconcileRepositories)
+	if err != nil {
+		return errors.Wrap(err, "failed to load reconcile repositories")
+	}
+	configuration.reconcileRepositories = reconcileRepositories
+
+	githubClient, err := newGitHubClient(configuration)
+	if err != nil {
+		return errors.Wrap(err, "failed to load GitHub credentials")
+	}
+	configuration.github = githubClient
+
+	webhookSourceRanges, err := parseSourceRanges(configuration.WebhookSourceRanges)
+	if err != nil {
+		return errors.Wrap(err, "failed to load webhook source ranges")
+	}
+	configuration.webhookSourceRanges = webhookSourceRanges
+
+	trustedProxies, err := parseSourceRanges(configuration.TrustedProxies)
+	if err != nil {
+		return errors.Wrap(err, "failed to load trusted proxies")
+	}
+	configuration.trustedProxies = trustedProxies
+
+	if err = checkWebhookSourceSettings(configuration); err != nil {
+		return err
+	}
+
+	userMapping, err := parseUserMapping(configuration.GitHubUserMapping)
+	if err != nil {
+		return errors.Wrap(err, "failed to load GitHub user mapping")
+	}
+	configuration.userMapping = userMapping
+
+	ciTarget, err := parseCITarget(configuration)
+	if err != nil {
+		return errors.Wrap(err, "failed to load CI channel")
+	}
+	configuration.ciTarget = ciTarget
+
+	ciBranches, err := parseBranchList(configuration.CIBranches)
+	if err != nil {
+		return errors.Wrap(err, "failed to load CI branches")
+	}
+	configuration.ciBranches = ciBranches
+
+	digest, err := parseDigestSchedule(configuration)
+	if err != nil {
+		return errors.Wrap(err, "failed to load digest settings")
+	}
+	configuration.digest = digest
+
+	messageTemplates, err := p.parseMessageTemplates(configuration.MessageTemplates)
+	if err != nil {
+		return errors.Wrap(err, "failed to load message templates")
+	}
+	configuration.messageTemplates = messageTemplates
+
 	p.setConfiguration(configuration)
-	p.startGithubEventListener()
+
+	// The rest of the configuration is still applied if webhooks cannot be handled
+	listenerErr := p.startGithubEventListener()
+
+	// The bot can only join teams once it exists, which is not yet the case when the plugin is
+	// activated. OnActivate joins the teams in that case.
+	if p.client != nil {
+		p.joinTeams()
+
+		if err = p.scheduleReconcileJob(); err != nil {
+			return err
+		}
+
+		if err = p.scheduleDigestJob(); err != nil {
+			return err
+		}
+
+		if err = p.scheduleStaleReminderJob(); err != nil {
+			return err
+		}
+	}
+
+	if listenerErr != nil {
+		return errors.Wrap(listenerErr, "failed to start GitHub event listener")
+	}
 
 	return nil
 }
+
+// ConfigurationWillBeSaved rejects plugin settings that would stop webhooks from being handled, and
+// message templates that fail, so that the problem is shown in the System Console instead of only in the server logs. Settings are
+// only checked when they are changed, so that saving other parts of the System Console is not
+// blocked by them. The error returned by OnConfigurationChange is logged instead.
+func (p *Plugin) ConfigurationWillBeSaved(newCfg *model.Config) (*model.Config, error) {
+	pluginID := p.API.GetPluginID()
+	settings, ok := newCfg.PluginSettings.Plugins[pluginID]
+	if !ok {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read plugin settings")
+	}
+
+	if currentCfg := p.API.GetConfig(); currentCfg != nil {
+		current, err := json.Marshal(currentCfg.PluginSettings.Plugins[pluginID])
+		if err == nil && bytes.Equal(current, data) {
+			return nil, nil
+		}
+	}
+
+	var configuration Configuration
+	if err = json.Unmarshal(data, &configuration); err != nil {
+		return nil, errors.Wrap(err, "failed to read plugin settings")
+	}
+
+	webhookSecrets, err := parseWebhookSecrets(configuration.WebhookSecretToken, configuration.PreviousWebhookSecrets)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load webhook secrets")
+	}
+	configuration.webhookSecrets = webhookSecrets
+
+	webhookEndpoints, err := parseWebhookEndpoints(&configuration)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load webhook endpoints")
+	}
+	configuration.webhookEndpoints = webhookEndpoints
+
+	if err = checkWebhookSecrets(&configuration); err != nil {
+		return nil, err
+	}
+
+	if _, err = p.parseMessageTemplates(configuration.MessageTemplates); err != nil {
+		return nil, errors.Wrap(err, "failed to load message templates")
+	}
+
+	return nil, nil
+}
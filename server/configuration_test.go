@@ -0,0 +1,62 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+func TestConfigurationWillBeSaved(t *testing.T) {
+	unsigned := map[string]any{"mattermost_team_name": "core"}
+	signed := map[string]any{"mattermost_team_name": "core", "webhook_secret_token": "secret"}
+
+	for name, tc := range map[string]struct {
+		current     map[string]any
+		saved       map[string]any
+		expectedErr bool
+	}{
+		"secret set": {
+			current: unsigned,
+			saved:   signed,
+		},
+		"secret removed": {
+			current:     signed,
+			saved:       unsigned,
+			expectedErr: true,
+		},
+		"other settings saved without a secret": {
+			current: unsigned,
+			saved:   map[string]any{"mattermost_team_name": "core"},
+		},
+		"failing message template": {
+			current:     signed,
+			saved:       map[string]any{"mattermost_team_name": "core", "webhook_secret_token": "secret", "message_templates": `{"issues": "{{.Issue.Titel}}"}`},
+			expectedErr: true,
+		},
+		"first save without a secret": {
+			saved:       unsigned,
+			expectedErr: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			p, api := newTestPlugin()
+			config := func(settings map[string]any) *model.Config {
+				config := &model.Config{}
+				config.SetDefaults()
+				if settings != nil {
+					config.PluginSettings.Plugins[api.GetPluginID()] = settings
+				}
+				return config
+			}
+			api.config = config(tc.current)
+
+			_, err := p.ConfigurationWillBeSaved(config(tc.saved))
+			if tc.expectedErr && err == nil {
+				t.Error("expected an error, got none")
+			}
+			if !tc.expectedErr && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
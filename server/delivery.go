@@ -0,0 +1,87 @@
+package main
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/pluginapi"
+)
+
+const (
+	deliveryKeyPrefix = "delivery_"
+	replyKeyPrefix    = "reply_"
+
+	// deliveryTTL is how long a processed delivery ID is remembered. GitHub only allows deliveries
+	// from the past few days to be redelivered, so anything older cannot be replayed.
+	deliveryTTL = 7 * 24 * time.Hour
+)
+
+func deliveryKey(deliveryID string) string {
+	return deliveryKeyPrefix + deliveryID
+}
+
+// claimDelivery records that the delivery is being processed. It returns false if the delivery
+// has already been claimed, either by an earlier delivery attempt or by a concurrent one.
+func (p *Plugin) claimDelivery(deliveryID string) (bool, error) {
+	if deliveryID == "" {
+		// Without a delivery ID there is nothing to deduplicate on
+		return true, nil
+	}
+
+	claimed, err := p.client.KV.Set(deliveryKey(deliveryID), time.Now().Unix(),
+		pluginapi.SetAtomic(nil), pluginapi.SetExpiry(deliveryTTL))
+	if err != nil {
+		return false, fmt.Errorf("failed to claim delivery %s: %w", deliveryID, err)
+	}
+
+	return claimed, nil
+}
+
+// releaseDelivery forgets a claimed delivery, so that a redelivery after a failure is processed again.
+func (p *Plugin) releaseDelivery(deliveryID string) error {
+	if deliveryID == "" {
+		return nil
+	}
+
+	if err := p.client.KV.Delete(deliveryKey(deliveryID)); err != nil {
+		return fmt.Errorf("failed to release delivery %s: %w", deliveryID, err)
+	}
+
+	return nil
+}
+
+// replyKey identifies the reply to a post for a delivery. Delivery IDs are too long to be combined
+// with a post ID in a KV key, so they are hashed together.
+func replyKey(deliveryID, postID string) string {
+	return fmt.Sprintf("%s%x", replyKeyPrefix, sha1.Sum([]byte(deliveryID+"_"+postID)))
+}
+
+// claimReply records that the delivery is being replied to the post with. It returns false if
+// that has already happened, e.g. before a handler is retried after failing for another post.
+func (p *Plugin) claimReply(deliveryID, postID string) (bool, error) {
+	if deliveryID == "" {
+		return true, nil
+	}
+
+	claimed, err := p.client.KV.Set(replyKey(deliveryID, postID), time.Now().Unix(),
+		pluginapi.SetAtomic(nil), pluginapi.SetExpiry(deliveryTTL))
+	if err != nil {
+		return false, fmt.Errorf("failed to claim reply to post %s: %w", postID, err)
+	}
+
+	return claimed, nil
+}
+
+// releaseReply forgets a claimed reply that could not be posted, so that it is posted on retry.
+func (p *Plugin) releaseReply(deliveryID, postID string) error {
+	if deliveryID == "" {
+		return nil
+	}
+
+	if err := p.client.KV.Delete(replyKey(deliveryID, postID)); err != nil {
+		return fmt.Errorf("failed to release reply to post %s: %w", postID, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,42 @@
+package main
+
+import "testing"
+
+func TestClaimDelivery(t *testing.T) {
+	for name, tc := range map[string]struct {
+		claimed    []string
+		released   []string
+		deliveryID string
+		expected   bool
+	}{
+		"new delivery":               {deliveryID: "a", expected: true},
+		"redelivery":                 {claimed: []string{"a"}, deliveryID: "a", expected: false},
+		"other delivery":             {claimed: []string{"a"}, deliveryID: "b", expected: true},
+		"redelivery after release":   {claimed: []string{"a"}, released: []string{"a"}, deliveryID: "a", expected: true},
+		"release of another":         {claimed: []string{"a"}, released: []string{"b"}, deliveryID: "a", expected: false},
+		"no delivery id":             {deliveryID: "", expected: true},
+		"no delivery id is repeated": {claimed: []string{""}, deliveryID: "", expected: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			p, _ := newTestPlugin()
+			for _, deliveryID := range tc.claimed {
+				if _, err := p.claimDelivery(deliveryID); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+			for _, deliveryID := range tc.released {
+				if err := p.releaseDelivery(deliveryID); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+
+			claimed, err := p.claimDelivery(tc.deliveryID)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if claimed != tc.expected {
+				t.Errorf("expected %v, got %v", tc.expected, claimed)
+			}
+		})
+	}
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/pluginapi/cluster"
+)
+
+const (
+	digestJobKey = "digest"
+
+	digestOff    = "off"
+	digestDaily  = "daily"
+	digestWeekly = "weekly"
+)
+
+// digestSchedule is parsed from the digest settings.
+type digestSchedule struct {
+	// Period is daily or weekly, or empty if scheduled digests are disabled.
+	Period   string
+	Hour     int
+	Minute   int
+	Weekday  time.Weekday
+	Location *time.Location
+	Targets  []routeTarget
+	// StaleAfter is how long a pull request can go without activity before it is listed as stale.
+	StaleAfter time.Duration
+}
+
+// parseDigestSchedule reads the digest settings. The period and stale threshold are also used by
+// on demand digests, so they are parsed even if scheduled digests are disabled.
+func parseDigestSchedule(config *Configuration) (*digestSchedule, error) {
+	schedule := &digestSchedule{
+		Location:   time.UTC,
+		StaleAfter: time.Duration(config.DigestStaleDays) * 24 * time.Hour,
+	}
+	if schedule.StaleAfter <= 0 {
+		schedule.StaleAfter = 7 * 24 * time.Hour
+	}
+
+	switch period := strings.TrimSpace(config.DigestSchedule); period {
+	case "", digestOff:
+	case digestDaily, digestWeekly:
+		schedule.Period = period
+	default:
+		return nil, fmt.Errorf("unsupported digest schedule %q, expected %s, %s or %s", period, digestOff, digestDaily, digestWeekly)
+	}
+
+	if zone := strings.TrimSpace(config.DigestTimeZone); zone != "" {
+		location, err := time.LoadLocation(zone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid digest time zone %q: %w", zone, err)
+		}
+		schedule.Location = location
+	}
+
+	if at := strings.TrimSpace(config.DigestTime); at != "" {
+		parsed, err := time.Parse("15:04", at)
+		if err != nil {
+			return nil, fmt.Errorf("invalid digest time %q, expected HH:MM", at)
+		}
+		schedule.Hour, schedule.Minute = parsed.Hour(), parsed.Minute()
+	}
+
+	schedule.Weekday = time.Monday
+	if weekday := strings.TrimSpace(config.DigestWeekday); weekday != "" {
+		found := false
+		for day := time.Sunday; day <= time.Saturday; day++ {
+			if strings.EqualFold(day.String(), weekday) {
+				schedule.Weekday, found = day, true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("invalid digest weekday %q", weekday)
+		}
+	}
+
+	teamName := strings.TrimSpace(config.MattermostTeamName)
+	for _, name := range strings.FieldsFunc(config.DigestChannels, func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	}) {
+		team, channel := splitChannelName(name)
+		if team == "" {
+			team = teamName
+		}
+		if team == "" {
+			return nil, fmt.Errorf("digest channel %q has no team and there is no default team", name)
+		}
+		schedule.Targets = append(schedule.Targets, routeTarget{Team: team, Channel: channel})
+	}
+
+	return schedule, nil
+}
+
+// period returns the length of the digest period, which is a day for on demand digests if
+// scheduled digests are disabled.
+func (s *digestSchedule) period() time.Duration {
+	if s.Period == digestWeekly {
+		return 7 * 24 * time.Hour
+	}
+
+	return 24 * time.Hour
+}
+
+// next returns the first time a digest is due after the given time.
+func (s *digestSchedule) next(after time.Time) time.Time {
+	local := after.In(s.Location)
+	next := time.Date(local.Year(), local.Month(), local.Day(), s.Hour, s.Minute, 0, 0, s.Location)
+
+	for !next.After(after) || (s.Period == digestWeekly && next.Weekday() != s.Weekday) {
+		next = next.AddDate(0, 0, 1)
+	}
+
+	return next
+}
+
+// scheduleDigestJob (re)starts the digest job with the configured schedule, or stops it if
+// scheduled digests are disabled.
+func (p *Plugin) scheduleDigestJob() error {
+	p.jobLock.Lock()
+	defer p.jobLock.Unlock()
+
+	if p.digestJob != nil {
+		if err := p.digestJob.Close(); err != nil {
+			return fmt.Errorf("failed to stop digest job: %w", err)
+		}
+		p.digestJob = nil
+	}
+
+	schedule := p.getConfiguration().digest
+	if schedule == nil || schedule.Period == "" || len(schedule.Targets) == 0 {
+		return nil
+	}
+
+	job, err := cluster.Schedule(p.API, digestJobKey, func(now time.Time, metadata cluster.JobMetadata) time.Duration {
+		last := metadata.LastFinished
+		if last.IsZero() {
+			last = now
+		}
+
+		return max(schedule.next(last).Sub(now), 0)
+	}, p.postDigests)
+	if err != nil {
+		return fmt.Errorf("failed to schedule digest job: %w", err)
+	}
+	p.digestJob = job
+
+	return nil
+}
+
+// postDigests posts the scheduled digest to every digest channel.
+func (p *Plugin) postDigests() {
+	schedule := p.getConfiguration().digest
+	if schedule == nil {
+		return
+	}
+
+	for _, target := range schedule.Targets {
+		if err := p.postDigest(schedule, target); err != nil {
+			p.client.Log.Error("Failed to post digest", "team", target.Team, "channel", target.Channel, "error", err.Error())
+		}
+	}
+}
+
+// postDigest posts a digest of the activity routed to the channel, covering the period up to
+// now. Channels that no events are routed to get an empty digest, so that activity of private
+// repositories does not leak into other channels.
+func (p *Plugin) postDigest(schedule *digestSchedule, target routeTarget) error {
+	botUserId := p.botUserId
+	if botUserId == nil {
+		return fmt.Errorf("bot user ID is nil")
+	}
+
+	channel, err := p.getChannel(target.Team, target.Channel)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	activities, err := p.getActivities(now.Add(-schedule.period()), now)
+	if err != nil {
+		return err
+	}
+
+	pullRequests, err := p.getTrackedPullRequests()
+	if err != nil {
+		return err
+	}
+
+	routes := p.routes()
+	includes := func(event routedEvent) bool {
+		return slices.Contains(matchRoutes(routes, event), target)
+	}
+
+	activities = slices.DeleteFunc(activities, func(a activity) bool {
+		return !includes(a.Event)
+	})
+
+	var stale []trackedPullRequest
+	for _, pullRequest := range pullRequests {
+		if !pullRequest.Draft && now.Sub(pullRequest.LastActivity) > schedule.StaleAfter && includes(pullRequest.Event) {
+			stale = append(stale, pullRequest)
+		}
+	}
+
+	message := digestMessage(schedule, now, activities, stale, p.mention)
+
+	err = p.client.Post.CreatePost(&model.Post{
+		UserId:    *botUserId,
+		ChannelId: channel.Id,
+		Message:   message,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create digest post in channel %s: %w", channel.Name, err)
+	}
+
+	return nil
+}
+
+// digestMessage formats the digest. Authors are formatted with mention.
+func digestMessage(schedule *digestSchedule, now time.Time, activities []activity, stale []trackedPullRequest, mention func(login string) string) string {
+	title := "Daily digest"
+	if schedule.Period == digestWeekly {
+		title = "Weekly digest"
+	}
+
+	lines := []string{fmt.Sprintf("#### %s for %s", title, now.In(schedule.Location).Format("Monday, January 2"))}
+
+	for _, section := range []struct {
+		kind  string
+		title string
+	}{
+		{activityPullRequestOpened, ":arrow_heading_up: Pull requests opened"},
+		{activityPullRequestMerged, ":white_check_mark: Pull requests merged"},
+		{activityIssueOpened, ":memo: Issues opened"},
+		{activityIssueClosed, ":heavy_check_mark: Issues closed"},
+		{activityReleasePublished, ":rocket: Releases published"},
+	} {
+		var items []string
+		for _, a := range activities {
+			if a.Kind != section.kind {
+				continue
+			}
+
+			name := a.Event.Repository
+			if a.Number != 0 {
+				name = fmt.Sprintf("%s#%d", name, a.Number)
+			}
+			items = append(items, fmt.Sprintf("* [%s](%s) %s by %s", name, a.URL, a.Title, mention(a.Author)))
+		}
+
+		if len(items) > 0 {
+			lines = append(lines, fmt.Sprintf("\n**%s (%d)**", section.title, len(items)))
+			lines = append(lines, items...)
+		}
+	}
+
+	if len(stale) > 0 {
+		slices.SortFunc(stale, func(a, b trackedPullRequest) int {
+			return a.LastActivity.Compare(b.LastActivity)
+		})
+
+		lines = append(lines, fmt.Sprintf("\n**:hourglass: Stale pull requests (%d)**", len(stale)))
+		for _, pullRequest := range stale {
+			lines = append(lines, fmt.Sprintf("* [%s#%d](%s) %s by %s, no activity for %d days",
+				pullRequest.Event.Repository, pullRequest.Number, pullRequest.URL, pullRequest.Title,
+				mention(pullRequest.Author), int(now.Sub(pullRequest.LastActivity).Hours()/24)))
+		}
+	}
+
+	if len(lines) == 1 {
+		lines = append(lines, "No activity.")
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// executeDigestNow posts a digest of the current period to the channel the command was run in.
+func (p *Plugin) executeDigestNow(channelID string) error {
+	schedule := p.getConfiguration().digest
+	if schedule == nil {
+		return errors.New("digest settings are not loaded")
+	}
+
+	target, err := p.getChannelTarget(channelID)
+	if err != nil {
+		return err
+	}
+	if target == nil {
+		return fmt.Errorf("channel %s was deleted", channelID)
+	}
+
+	return p.postDigest(schedule, *target)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDigestScheduleNext(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for name, tc := range map[string]struct {
+		schedule digestSchedule
+		after    time.Time
+		expected time.Time
+	}{
+		"daily later today": {
+			schedule: digestSchedule{Period: digestDaily, Hour: 9, Location: time.UTC},
+			after:    time.Date(2024, 3, 4, 8, 0, 0, 0, time.UTC),
+			expected: time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC),
+		},
+		"daily tomorrow": {
+			schedule: digestSchedule{Period: digestDaily, Hour: 9, Location: time.UTC},
+			after:    time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC),
+			expected: time.Date(2024, 3, 5, 9, 0, 0, 0, time.UTC),
+		},
+		"weekly": {
+			schedule: digestSchedule{Period: digestWeekly, Hour: 9, Minute: 30, Weekday: time.Friday, Location: time.UTC},
+			after:    time.Date(2024, 3, 4, 12, 0, 0, 0, time.UTC),
+			expected: time.Date(2024, 3, 8, 9, 30, 0, 0, time.UTC),
+		},
+		"time zone": {
+			schedule: digestSchedule{Period: digestDaily, Hour: 9, Location: berlin},
+			after:    time.Date(2024, 3, 4, 8, 30, 0, 0, time.UTC),
+			expected: time.Date(2024, 3, 5, 8, 0, 0, 0, time.UTC),
+		},
+		"across daylight saving time": {
+			schedule: digestSchedule{Period: digestDaily, Hour: 9, Location: berlin},
+			after:    time.Date(2024, 3, 30, 9, 0, 0, 0, time.UTC),
+			expected: time.Date(2024, 3, 31, 7, 0, 0, 0, time.UTC),
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if next := tc.schedule.next(tc.after); !next.Equal(tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, next.UTC())
+			}
+		})
+	}
+}
+
+func TestDigestMessage(t *testing.T) {
+	schedule := &digestSchedule{Period: digestDaily, Location: time.UTC}
+	now := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	mention := func(login string) string {
+		return "@" + login
+	}
+
+	for name, tc := range map[string]struct {
+		activities []activity
+		stale      []trackedPullRequest
+		expected   string
+	}{
+		"no activity": {
+			expected: "#### Daily digest for Monday, March 4\nNo activity.",
+		},
+		"activity and stale pull requests": {
+			activities: []activity{
+				{Kind: activityIssueOpened, Event: routedEvent{Repository: "holochain/holochain"}, Number: 2, Title: "Crash", URL: "https://github.com/holochain/holochain/issues/2", Author: "alice"},
+				{Kind: activityPullRequestMerged, Event: routedEvent{Repository: "holochain/holochain"}, Number: 1, Title: "Fix crash", URL: "https://github.com/holochain/holochain/pull/1", Author: "bob"},
+				{Kind: activityReleasePublished, Event: routedEvent{Repository: "holochain/holochain"}, Title: "v0.4.0", URL: "https://github.com/holochain/holochain/releases/tag/v0.4.0", Author: "carol"},
+			},
+			stale: []trackedPullRequest{
+				{Event: routedEvent{Repository: "holochain/lair"}, Number: 5, Title: "Old", URL: "https://github.com/holochain/lair/pull/5", Author: "dave", LastActivity: now.Add(-10 * 24 * time.Hour)},
+			},
+			expected: "#### Daily digest for Monday, March 4\n" +
+				"\n**:white_check_mark: Pull requests merged (1)**\n" +
+				"* [holochain/holochain#1](https://github.com/holochain/holochain/pull/1) Fix crash by @bob\n" +
+				"\n**:memo: Issues opened (1)**\n" +
+				"* [holochain/holochain#2](https://github.com/holochain/holochain/issues/2) Crash by @alice\n" +
+				"\n**:rocket: Releases published (1)**\n" +
+				"* [holochain/holochain](https://github.com/holochain/holochain/releases/tag/v0.4.0) v0.4.0 by @carol\n" +
+				"\n**:hourglass: Stale pull requests (1)**\n" +
+				"* [holochain/lair#5](https://github.com/holochain/lair/pull/5) Old by @dave, no activity for 10 days",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if message := digestMessage(schedule, now, tc.activities, tc.stale, mention); message != tc.expected {
+				t.Errorf("expected message\n%s\ngot\n%s", tc.expected, message)
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+// webhookEndpointID restricts endpoint IDs to characters that can be used in a URL path as is.
+var webhookEndpointID = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// webhookEndpoint is a webhook URL of its own, /github/{id}, so that repositories or organizations
+// can be given a secret that is not shared with anyone else, and be revoked on their own. Events
+// received on the endpoint are only sent to its channel, and only change the bot's posts there.
+// Events received elsewhere are never sent to the endpoint's channel.
+type webhookEndpoint struct {
+	ID     string `json:"id"`
+	Secret string `json:"secret"`
+
+	// The route is the endpoint's channel. Its repositories, which are required, are also the only
+	// repositories that the endpoint accepts events for.
+	route
+}
+
+// parseWebhookEndpoints reads the webhook endpoints from the configuration.
+func parseWebhookEndpoints(config *Configuration) ([]webhookEndpoint, error) {
+	var endpoints []webhookEndpoint
+	if value := strings.TrimSpace(config.WebhookEndpoints); value != "" {
+		if err := json.Unmarshal([]byte(value), &endpoints); err != nil {
+			return nil, fmt.Errorf("failed to parse webhook endpoints: %w", err)
+		}
+	}
+
+	for i := range endpoints {
+		endpoint := &endpoints[i]
+
+		if !webhookEndpointID.MatchString(endpoint.ID) {
+			return nil, fmt.Errorf("invalid webhook endpoint %d: id %q must only contain letters, digits, '-' and '_'", i+1, endpoint.ID)
+		}
+		if slices.ContainsFunc(endpoints[:i], func(other webhookEndpoint) bool {
+			return other.ID == endpoint.ID
+		}) {
+			return nil, fmt.Errorf("invalid webhook endpoint %d: id %q is used more than once", i+1, endpoint.ID)
+		}
+		if endpoint.Secret == "" {
+			return nil, fmt.Errorf("invalid webhook endpoint %q: secret is not set", endpoint.ID)
+		}
+		if len(endpoint.Repositories) == 0 {
+			return nil, fmt.Errorf("invalid webhook endpoint %q: repositories are not set", endpoint.ID)
+		}
+
+		if err := endpoint.resolve(strings.TrimSpace(config.MattermostTeamName)); err != nil {
+			return nil, fmt.Errorf("invalid webhook endpoint %q: %w", endpoint.ID, err)
+		}
+	}
+
+	return endpoints, nil
+}
+
+// webhookEndpointRoutes returns the routes to the default channels of the endpoints.
+func webhookEndpointRoutes(endpoints []webhookEndpoint) []route {
+	routes := make([]route, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		routes = append(routes, endpoint.route)
+	}
+
+	return routes
+}
+
+// webhookEndpointContextKey is the context key of the webhook endpoint that the event being
+// handled was received on.
+type webhookEndpointContextKey struct{}
+
+// withWebhookEndpoint returns a context for handling an event that was received on the endpoint,
+// which is nil for events received on /github.
+func withWebhookEndpoint(ctx context.Context, endpoint *webhookEndpoint) context.Context {
+	if endpoint == nil {
+		return ctx
+	}
+
+	return context.WithValue(ctx, webhookEndpointContextKey{}, endpoint)
+}
+
+// eventEndpoint returns the webhook endpoint that the event being handled was received on, or nil
+// if it was received on /github.
+func eventEndpoint(ctx context.Context) *webhookEndpoint {
+	endpoint, _ := ctx.Value(webhookEndpointContextKey{}).(*webhookEndpoint)
+	return endpoint
+}
+
+// eventRoutes returns the routes of the event being handled. These are the routes of the
+// configuration and the channel subscriptions, or only the route of the webhook endpoint that the
+// event was received on.
+func (p *Plugin) eventRoutes(ctx context.Context) []route {
+	if endpoint := eventEndpoint(ctx); endpoint != nil {
+		return []route{endpoint.route}
+	}
+
+	return p.routes()
+}
+
+// eventPosts returns the bot's posts about the object that the event being handled may change.
+// Events received on a webhook endpoint only change the post in the endpoint's channel.
+func (p *Plugin) eventPosts(ctx context.Context, obj githubObject) ([]*model.Post, error) {
+	endpoint := eventEndpoint(ctx)
+	if endpoint == nil {
+		return p.getIndexedPosts(obj)
+	}
+
+	channel, err := p.getChannel(endpoint.Team, endpoint.Channel)
+	if err != nil {
+		return nil, err
+	}
+
+	post, err := p.getIndexedPost(obj, channel.Id)
+	if err != nil || post == nil {
+		return nil, err
+	}
+
+	return []*model.Post{post}, nil
+}
+
+// getWebhookEndpoint returns the endpoint with the ID, or nil if there is none.
+func (c *Configuration) getWebhookEndpoint(id string) *webhookEndpoint {
+	i := slices.IndexFunc(c.webhookEndpoints, func(endpoint webhookEndpoint) bool {
+		return endpoint.ID == id
+	})
+	if i < 0 {
+		return nil
+	}
+
+	return &c.webhookEndpoints[i]
+}
+
+// secrets returns the secret that the endpoint's deliveries are signed with.
+func (e *webhookEndpoint) secrets() []webhookSecret {
+	return []webhookSecret{{Name: "endpoint " + e.ID, Secret: e.Secret}}
+}
+
+// checkRepository rejects deliveries for repositories that the endpoint does not accept, so that
+// its secret cannot be used to post events of other repositories.
+func (e *webhookEndpoint) checkRepository(eventName string, payload []byte) error {
+	if eventName == "ping" {
+		return nil
+	}
+
+	var event struct {
+		Repository struct {
+			FullName string `json:"full_name"`
+		} `json:"repository"`
+	}
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return newWebhookError(http.StatusBadRequest, "could not read repository of webhook payload: %w", err)
+	}
+
+	// An endpoint without repositories is rejected by the configuration, but must not accept every
+	// repository if it gets through
+	repositories := route{Repositories: e.Repositories}
+	if len(e.Repositories) == 0 || event.Repository.FullName == "" || !repositories.matches(routedEvent{Repository: event.Repository.FullName}) {
+		return newWebhookError(http.StatusForbidden, "webhook endpoint %s does not accept events for repository %q", e.ID, event.Repository.FullName)
+	}
+
+	return nil
+}
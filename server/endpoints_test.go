@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/google/go-github/v76/github"
+)
+
+func TestParseWebhookEndpoints(t *testing.T) {
+	for name, tc := range map[string]struct {
+		endpoints   string
+		expected    []webhookEndpoint
+		expectedErr bool
+	}{
+		"no endpoints": {},
+		"endpoint": {
+			endpoints: `[{"id": "lair", "secret": "secret", "repositories": ["holochain/lair"], "events": ["pull_request"], "channel": "tooling/lair"}]`,
+			expected: []webhookEndpoint{{
+				ID:     "lair",
+				Secret: "secret",
+				route: route{
+					Repositories: []string{"holochain/lair"},
+					Events:       []string{"pull_request"},
+					Team:         "tooling",
+					Channel:      "lair",
+				},
+			}},
+		},
+		"default team": {
+			endpoints: `[{"id": "partner", "secret": "secret", "repositories": ["partner/*"], "channel": "partner"}]`,
+			expected: []webhookEndpoint{{
+				ID:     "partner",
+				Secret: "secret",
+				route:  route{Repositories: []string{"partner/*"}, Team: "holochain", Channel: "partner"},
+			}},
+		},
+		"invalid id": {
+			endpoints:   `[{"id": "a/b", "secret": "secret", "repositories": ["partner/*"], "channel": "partner"}]`,
+			expectedErr: true,
+		},
+		"duplicate id": {
+			endpoints:   `[{"id": "a", "secret": "secret", "repositories": ["partner/*"], "channel": "one"}, {"id": "a", "secret": "secret", "repositories": ["partner/*"], "channel": "two"}]`,
+			expectedErr: true,
+		},
+		"missing secret": {
+			endpoints:   `[{"id": "partner", "repositories": ["partner/*"], "channel": "partner"}]`,
+			expectedErr: true,
+		},
+		"missing repositories": {
+			endpoints:   `[{"id": "partner", "secret": "secret", "channel": "partner"}]`,
+			expectedErr: true,
+		},
+		"missing channel": {
+			endpoints:   `[{"id": "partner", "secret": "secret", "repositories": ["partner/*"]}]`,
+			expectedErr: true,
+		},
+		"invalid json": {
+			endpoints:   `{"id": "partner"}`,
+			expectedErr: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			endpoints, err := parseWebhookEndpoints(&Configuration{MattermostTeamName: "holochain", WebhookEndpoints: tc.endpoints})
+			if tc.expectedErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", endpoints)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(endpoints, tc.expected) {
+				t.Errorf("expected %+v, got %+v", tc.expected, endpoints)
+			}
+		})
+	}
+}
+
+func TestWebhookEndpointCheckRepository(t *testing.T) {
+	endpoint := &webhookEndpoint{ID: "lair", route: route{Repositories: []string{"holochain/lair*"}}}
+
+	for name, tc := range map[string]struct {
+		endpoint       *webhookEndpoint
+		eventName      string
+		payload        string
+		expectedStatus int
+	}{
+		"accepted repository": {
+			endpoint:  endpoint,
+			eventName: "pull_request",
+			payload:   `{"repository": {"full_name": "holochain/lair-keystore"}}`,
+		},
+		"other repository": {
+			endpoint:       endpoint,
+			eventName:      "pull_request",
+			payload:        `{"repository": {"full_name": "holochain/holochain"}}`,
+			expectedStatus: http.StatusForbidden,
+		},
+		"no repository": {
+			endpoint:       endpoint,
+			eventName:      "pull_request",
+			payload:        `{}`,
+			expectedStatus: http.StatusForbidden,
+		},
+		"ping": {
+			endpoint:  endpoint,
+			eventName: "ping",
+			payload:   `{}`,
+		},
+		"no repositories": {
+			endpoint:       &webhookEndpoint{ID: "partner"},
+			eventName:      "pull_request",
+			payload:        `{"repository": {"full_name": "partner/project"}}`,
+			expectedStatus: http.StatusForbidden,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := tc.endpoint.checkRepository(tc.eventName, []byte(tc.payload))
+			if tc.expectedStatus != 0 {
+				if err == nil {
+					t.Fatal("expected an error, got nil")
+				}
+				if status := webhookErrorStatus(err); status != tc.expectedStatus {
+					t.Errorf("expected status %d, got %d", tc.expectedStatus, status)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestEndpointEventIsolation(t *testing.T) {
+	p, api := newTestPlugin()
+	api.addChannel("holochain", "lair", "a")
+
+	endpoint := &webhookEndpoint{ID: "lair", route: route{Repositories: []string{"holochain/*"}, Team: "holochain", Channel: "lair"}}
+	ctx := withWebhookEndpoint(context.Background(), endpoint)
+
+	if routes := p.eventRoutes(ctx); !reflect.DeepEqual(routes, []route{endpoint.route}) {
+		t.Errorf("expected only the endpoint's route, got %+v", routes)
+	}
+
+	obj := pullRequestObject(testRepo, &github.PullRequest{Number: github.Ptr(1)})
+	open := textContent(":new: Fix crash\n" + obj.Tag)
+	merged := textContent(":tada: Fix crash\n" + obj.Tag)
+	endpointPost := createIndexedPost(t, p, obj, "a", open, true)
+	otherPost := createIndexedPost(t, p, obj, "b", open, true)
+
+	if err := p.updateMessages(ctx, obj, merged); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := p.unpinMessages(ctx, obj); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if post := api.post(endpointPost.Id); post.Message != merged.Message || post.IsPinned {
+		t.Errorf("expected the endpoint's post to be updated and unpinned, got %q, pinned %v", post.Message, post.IsPinned)
+	}
+	if post := api.post(otherPost.Id); post.Message != open.Message || !post.IsPinned {
+		t.Errorf("expected the post in another channel to be left alone, got %q, pinned %v", post.Message, post.IsPinned)
+	}
+}
@@ -2,334 +2,529 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/cbrgm/githubevents/v2/githubevents"
 	"github.com/google/go-github/v76/github"
 	"github.com/mattermost/mattermost/server/public/model"
 	"github.com/mattermost/mattermost/server/public/plugin"
+	"github.com/mattermost/mattermost/server/public/pluginapi"
 )
 
-func (p *Plugin) startGithubEventListener() {
-	config := p.configuration.Clone()
+// startGithubEventListener (re)starts handling webhook requests with the current configuration. It
+// refuses to handle them at all if they cannot be authenticated, unless that has been allowed.
+func (p *Plugin) startGithubEventListener() error {
+	config := p.getConfiguration()
+
+	if err := checkWebhookSecrets(config); err != nil {
+		p.eventHandlers = nil
+		p.endpointEventHandlers = nil
+		return err
+	}
 
 	eventHandler := githubevents.New(config.WebhookSecretToken)
 
-	teamName := strings.TrimSpace(config.MattermostTeamName)
-	issueFeed := strings.TrimSpace(config.MattermostIssueFeedChannelName)
-	prFeed := strings.TrimSpace(config.MattermostPullRequestChannelName)
-	releaseFeed := strings.TrimSpace(config.MattermostReleaseCreatedChannelName)
-
-	if teamName != "" && issueFeed != "" {
-		eventHandler.OnIssuesEventOpened(
-			func(ctx context.Context, deliveryID string, eventName string, event *github.IssuesEvent) error {
-				repo := event.GetRepo()
-				issue := event.GetIssue()
-
-				tag := fmt.Sprintf("#%s.%s.%d", repo.GetOwner().GetName(), repo.GetName(), issue.GetNumber())
-				posts, err := p.findPostsByTerm(tag, teamName, issueFeed)
-				if err != nil {
-					return err
-				}
-				if len(posts) > 0 {
-					// Skip creating duplicate posts for this issue
-					return nil
-				}
-
-				return p.sendMessage(
-					fmt.Sprintf("%s\n%s\n%s", issue.GetTitle(), issue.GetHTMLURL(), tag),
-					teamName,
-					issueFeed, false)
+	// Routes are looked up for every event, as channel subscriptions can change at any time, and
+	// events received on a webhook endpoint are also routed to the endpoint's channel
+	eventHandler.OnIssuesEventOpened(
+		func(ctx context.Context, deliveryID string, eventName string, event *github.IssuesEvent) error {
+			return forEachTarget(p.eventRoutes(ctx), issuesRoutedEvent(event), func(target routeTarget) error {
+				return p.postIssue(event, target.Team, target.Channel)
 			})
-	} else {
-		println("Mattermost team name or issue feed channel name is not set, skipping issue event listener setup")
-	}
-
-	if teamName != "" && prFeed != "" {
-		eventHandler.OnPullRequestEventOpened(
-			func(ctx context.Context, deliveryID string, eventName string, event *github.PullRequestEvent) error {
-				repo := event.GetRepo()
-				pullRequest := event.GetPullRequest()
-
-				// Skip draft pull requests
-				if pullRequest.GetDraft() {
-					return nil
-				}
-
-				tag := fmt.Sprintf("#%s.%s.%d", repo.GetOwner().GetName(), repo.GetName(), pullRequest.GetNumber())
-				posts, err := p.findPostsByTerm(tag, teamName, prFeed)
-				if err != nil {
-					return fmt.Errorf("failed to find posts by tag %s: %w", tag, err)
-				}
-
-				if len(posts) > 0 {
-					// Ensure that the post is pinned
-					for _, post := range posts {
-						if !post.IsPinned {
-							post.IsPinned = true
-							err = p.client.Post.UpdatePost(post)
-							if err != nil {
-								return fmt.Errorf("failed to update post in channel %s: %w", prFeed, err)
-							}
-						}
-					}
-
-					// Pull request message already exists, do not send a duplicate
-					return nil
-				}
-
-				return p.sendMessage(
-					fmt.Sprintf("%s\n%s\n%s", pullRequest.GetTitle(), pullRequest.GetHTMLURL(), tag),
-					teamName,
-					prFeed, true)
+		})
+
+	eventHandler.OnIssuesEventEdited(
+		func(ctx context.Context, deliveryID string, eventName string, event *github.IssuesEvent) error {
+			obj := issueObject(event.GetRepo(), event.GetIssue())
+
+			return p.updateMessages(ctx, obj, p.issueContent(event, obj))
+		})
+
+	eventHandler.OnPullRequestEventOpened(
+		func(ctx context.Context, deliveryID string, eventName string, event *github.PullRequestEvent) error {
+			// Skip draft pull requests
+			if event.GetPullRequest().GetDraft() {
+				return nil
+			}
+
+			return forEachTarget(p.eventRoutes(ctx), pullRequestRoutedEvent(event), func(target routeTarget) error {
+				return p.ensurePullRequestPinned(event, target.Team, target.Channel)
 			})
+		})
 
-		eventHandler.OnPullRequestEventReadyForReview(
-			func(ctx context.Context, deliveryID string, eventName string, event *github.PullRequestEvent) error {
-				repo := event.GetRepo()
-				pullRequest := event.GetPullRequest()
-
-				tag := fmt.Sprintf("#%s.%s.%d", repo.GetOwner().GetName(), repo.GetName(), pullRequest.GetNumber())
-				posts, err := p.findPostsByTerm(tag, teamName, prFeed)
-				if err != nil {
-					return fmt.Errorf("failed to find posts by tag %s: %w", tag, err)
-				}
-
-				if len(posts) > 0 {
-					// Ensure that the post is pinned
-					for _, post := range posts {
-						if !post.IsPinned {
-							post.IsPinned = true
-							err = p.client.Post.UpdatePost(post)
-							if err != nil {
-								return fmt.Errorf("failed to update post in channel %s: %w", prFeed, err)
-							}
-						}
-					}
-
-					// Pull request message already exists, do not send a duplicate
-					return nil
-				}
-
-				return p.sendMessage(
-					fmt.Sprintf("%s\n%s\n%s", pullRequest.GetTitle(), pullRequest.GetHTMLURL(), tag),
-					teamName,
-					prFeed, true)
+	eventHandler.OnPullRequestEventReadyForReview(
+		func(ctx context.Context, deliveryID string, eventName string, event *github.PullRequestEvent) error {
+			return forEachTarget(p.eventRoutes(ctx), pullRequestRoutedEvent(event), func(target routeTarget) error {
+				return p.ensurePullRequestPinned(event, target.Team, target.Channel)
 			})
+		})
+
+	eventHandler.OnPullRequestEventClosed(
+		func(ctx context.Context, deliveryID string, eventName string, event *github.PullRequestEvent) error {
+			obj := pullRequestObject(event.GetRepo(), event.GetPullRequest())
+
+			if err := p.unpinMessages(ctx, obj); err != nil {
+				return err
+			}
 
-		eventHandler.OnPullRequestEventClosed(
-			func(ctx context.Context, deliveryID string, eventName string, event *github.PullRequestEvent) error {
-				repo := event.GetRepo()
-				pullRequest := event.GetPullRequest()
-				term := fmt.Sprintf("#%s.%s.%d", repo.GetOwner().GetName(), repo.GetName(), pullRequest.GetNumber())
+			if err := p.updateMessages(ctx, obj, p.pullRequestContent(event, obj)); err != nil {
+				return err
+			}
+
+			return p.replyToPosts(ctx, deliveryID, obj, pullRequestClosedMessage(event))
+		})
+
+	eventHandler.OnPullRequestEventReopened(
+		func(ctx context.Context, deliveryID string, eventName string, event *github.PullRequestEvent) error {
+			obj := pullRequestObject(event.GetRepo(), event.GetPullRequest())
+
+			if err := p.updateMessages(ctx, obj, p.pullRequestContent(event, obj)); err != nil {
+				return err
+			}
+
+			if err := p.replyToPosts(ctx, deliveryID, obj, pullRequestReopenedMessage(event)); err != nil {
+				return err
+			}
+
+			// Reopened drafts are pinned once they are ready for review
+			if event.GetPullRequest().GetDraft() {
+				return nil
+			}
 
-				return p.unpinMessages(term, teamName, prFeed)
+			return forEachTarget(p.eventRoutes(ctx), pullRequestRoutedEvent(event), func(target routeTarget) error {
+				return p.ensurePullRequestPinned(event, target.Team, target.Channel)
 			})
-	} else {
-		println("Mattermost team name or pull request feed channel name is not set, skipping pull request event listener setup")
-	}
-
-	if teamName != "" && releaseFeed != "" {
-		eventHandler.OnReleaseEventReleased(
-			func(ctx context.Context, deliveryID string, eventName string, event *github.ReleaseEvent) error {
-				repo := event.GetRepo()
-				release := event.GetRelease()
-
-				tag := fmt.Sprintf("#%s.%s.%s", repo.GetOwner().GetName(), repo.GetName(), release.GetTagName())
-				posts, err := p.findPostsByTerm(tag, teamName, releaseFeed)
-				if err != nil {
-					return fmt.Errorf("failed to find posts by tag %s: %w", releaseFeed, err)
-				}
-				if len(posts) > 0 {
-					// Skip creating duplicate events
-					return nil
-				}
-
-				return p.sendMessage(
-					fmt.Sprintf("%s\n%s", releaseTable(repo, release, false), tag),
-					teamName,
-					releaseFeed, false)
+		})
+
+	eventHandler.OnPullRequestEventConvertedToDraft(
+		func(ctx context.Context, deliveryID string, eventName string, event *github.PullRequestEvent) error {
+			pullRequest := event.GetPullRequest()
+			obj := pullRequestObject(event.GetRepo(), pullRequest)
+
+			if err := p.updateMessages(ctx, obj, p.pullRequestContent(event, obj)); err != nil {
+				return err
+			}
+
+			return p.unpinMessages(ctx, obj)
+		})
+
+	eventHandler.OnPullRequestEventEdited(
+		func(ctx context.Context, deliveryID string, eventName string, event *github.PullRequestEvent) error {
+			pullRequest := event.GetPullRequest()
+			obj := pullRequestObject(event.GetRepo(), pullRequest)
+
+			return p.updateMessages(ctx, obj, p.pullRequestContent(event, obj))
+		})
+
+	eventHandler.OnReleaseEventReleased(
+		func(ctx context.Context, deliveryID string, eventName string, event *github.ReleaseEvent) error {
+			return forEachTarget(p.eventRoutes(ctx), releaseRoutedEvent(event), func(target routeTarget) error {
+				return p.postRelease(event, target.Team, target.Channel, false)
 			})
+		})
 
-		eventHandler.OnReleaseEventPreReleased(
-			func(ctx context.Context, deliveryID string, eventName string, event *github.ReleaseEvent) error {
-				repo := event.GetRepo()
-				release := event.GetRelease()
-
-				tag := fmt.Sprintf("#%s.%s.%s", repo.GetOwner().GetName(), repo.GetName(), release.GetTagName())
-				posts, err := p.findPostsByTerm(tag, teamName, releaseFeed)
-				if err != nil {
-					return fmt.Errorf("failed to find posts by tag %s: %w", releaseFeed, err)
-				}
-				if len(posts) > 0 {
-					// Skip creating duplicate events
-					return nil
-				}
-
-				return p.sendMessage(
-					fmt.Sprintf("%s\n%s", releaseTable(repo, release, true), tag),
-					teamName,
-					releaseFeed, false)
+	eventHandler.OnReleaseEventPreReleased(
+		func(ctx context.Context, deliveryID string, eventName string, event *github.ReleaseEvent) error {
+			return forEachTarget(p.eventRoutes(ctx), releaseRoutedEvent(event), func(target routeTarget) error {
+				return p.postRelease(event, target.Team, target.Channel, true)
 			})
-	} else {
-		println("Mattermost team name or release feed channel name is not set, skipping release event listener setup")
+		})
+
+	// The other parts of the plugin get their own handlers, so that they are retried separately.
+	// CI alerts and activity are reported to channels of their own, which events received on a
+	// webhook endpoint must not reach.
+	eventHandlers := []*githubevents.EventHandler{eventHandler}
+	endpointEventHandlers := []*githubevents.EventHandler{eventHandler}
+	for _, part := range []struct {
+		register func(eventHandler *githubevents.EventHandler)
+		endpoint bool
+	}{
+		{register: func(eventHandler *githubevents.EventHandler) { p.registerIssueLifecycleHandlers(eventHandler, config) }, endpoint: true},
+		{register: p.registerPullRequestThreadHandlers, endpoint: true},
+		{register: p.registerReviewHandlers, endpoint: true},
+		{register: func(eventHandler *githubevents.EventHandler) { p.registerCIHandlers(eventHandler, config) }},
+		{register: p.registerActivityHandlers},
+	} {
+		eventHandler := githubevents.New(config.WebhookSecretToken)
+		part.register(eventHandler)
+		eventHandlers = append(eventHandlers, eventHandler)
+		if part.endpoint {
+			endpointEventHandlers = append(endpointEventHandlers, eventHandler)
+		}
 	}
 
-	p.eventHandler = eventHandler
-}
+	p.eventHandlers = eventHandlers
+	p.endpointEventHandlers = endpointEventHandlers
 
-func releaseTable(repo *github.Repository, release *github.RepositoryRelease, isPreRelease bool) string {
-	var preReleaseRow string
-	if isPreRelease {
-		preReleaseRow = "| Pre-release | Yes |"
-	} else {
-		preReleaseRow = "| Pre-release | No |"
-	}
-
-	return fmt.Sprintf(`
-| Key        | Value |
-| ---------- | ----- |
-| Repository | %s    |
-| Name       | %s    |
-| Tag        | %s    |
-| URL        | %s    |
-%s
-`, repo.GetName(), release.GetName(), release.GetTagName(), release.GetHTMLURL(), preReleaseRow)
+	return nil
 }
 
-func (p *Plugin) sendMessage(message, teamName, channelName string, pinned bool) error {
-	botUserId := p.botUserId
-	if botUserId == nil {
-		return fmt.Errorf("bot user ID is nil")
-	}
+func (p *Plugin) postIssue(event *github.IssuesEvent, teamName, channelName string) error {
+	issue := event.GetIssue()
+	obj := issueObject(event.GetRepo(), issue)
 
-	team, err := p.ensureTeam(*botUserId, teamName)
+	channel, err := p.getChannel(teamName, channelName)
 	if err != nil {
-		return fmt.Errorf("failed to ensure team %s: %w", teamName, err)
+		return err
 	}
 
-	channel, err := p.client.Channel.GetByName(team.Id, channelName, false)
+	post, err := p.getIndexedPost(obj, channel.Id)
 	if err != nil {
-		return fmt.Errorf("failed to get channel by name %s: %w", channelName, err)
+		return err
 	}
-
-	err = p.client.Post.CreatePost(&model.Post{
-		IsPinned:  pinned,
-		UserId:    *botUserId,
-		ChannelId: channel.Id,
-		Message:   message,
-	})
-	if err != nil {
-		return fmt.Errorf("failed to create post in channel %s: %w", channelName, err)
+	if post != nil {
+		// Skip creating duplicate posts for this issue
+		return nil
 	}
 
-	return nil
+	return p.sendMessage(obj, p.issueContent(event, obj), channel, false)
 }
 
-func (p *Plugin) unpinMessages(term, teamName, channelName string) error {
-	posts, err := p.findPostsByTerm(term, teamName, channelName)
+// ensurePullRequestPinned posts the pull request of the event to the channel, or pins the existing
+// post if the pull request has been posted before.
+func (p *Plugin) ensurePullRequestPinned(event *github.PullRequestEvent, teamName, channelName string) error {
+	obj := pullRequestObject(event.GetRepo(), event.GetPullRequest())
+
+	channel, err := p.getChannel(teamName, channelName)
 	if err != nil {
-		return fmt.Errorf("failed to find posts by term %s: %w", term, err)
+		return err
 	}
 
-	for _, post := range posts {
-		if post.IsPinned {
-			post.IsPinned = false
+	post, err := p.getIndexedPost(obj, channel.Id)
+	if err != nil {
+		return fmt.Errorf("failed to find post for %s: %w", obj.Tag, err)
+	}
+
+	if post != nil {
+		// Ensure that the post is pinned and no longer shows the pull request as a draft
+		content := p.pullRequestContent(event, obj)
+		if !post.IsPinned || !content.matches(post) {
+			post.IsPinned = true
+			content.apply(post)
 			err = p.client.Post.UpdatePost(post)
 			if err != nil {
 				return fmt.Errorf("failed to update post in channel %s: %w", channelName, err)
 			}
 		}
+	} else if err = p.sendMessage(obj, p.pullRequestContent(event, obj), channel, true); err != nil {
+		return err
 	}
 
-	return nil
+	return p.updatePinnedPullRequests(obj.RepoID, []int64{obj.Number}, nil)
 }
 
-func (p *Plugin) findPostsByTerm(term, teamName, channelName string) ([]*model.Post, error) {
+func (p *Plugin) postRelease(event *github.ReleaseEvent, teamName, channelName string, isPreRelease bool) error {
+	repo := event.GetRepo()
+	release := event.GetRelease()
+	obj := releaseObject(repo, release)
+
+	channel, err := p.getChannel(teamName, channelName)
+	if err != nil {
+		return err
+	}
+
+	post, err := p.getIndexedPost(obj, channel.Id)
+	if err != nil {
+		return fmt.Errorf("failed to find post for %s: %w", obj.Tag, err)
+	}
+	if post != nil {
+		// Skip creating duplicate events
+		return nil
+	}
+
+	content := postContent{
+		Message:     obj.Tag,
+		Attachments: []*model.SlackAttachment{releaseAttachment(repo, release, isPreRelease, p.getConfiguration().releaseNotesLength())},
+	}
+	if message, ok := p.templateMessage(eventRelease, event.GetAction(), event, obj); ok {
+		content = textContent(message)
+	}
+
+	return p.sendMessage(obj, content, channel, false)
+}
+
+// issueContent is the bot's post about the issue of the event, reflecting the issue's current
+// state.
+func (p *Plugin) issueContent(event *github.IssuesEvent, obj githubObject) postContent {
+	if message, ok := p.templateMessage(eventIssues, event.GetAction(), event, obj); ok {
+		return textContent(message)
+	}
+
+	return postContent{
+		Message:     obj.Tag,
+		Attachments: []*model.SlackAttachment{p.issueAttachment(event.GetIssue())},
+	}
+}
+
+// pullRequestContent is the bot's post about the pull request of the event, reflecting the pull
+// request's current state.
+func (p *Plugin) pullRequestContent(event *github.PullRequestEvent, obj githubObject) postContent {
+	if message, ok := p.templateMessage(eventPullRequest, event.GetAction(), event, obj); ok {
+		return textContent(message)
+	}
+
+	return postContent{
+		Message:     obj.Tag,
+		Attachments: []*model.SlackAttachment{p.pullRequestAttachment(event.GetRepo(), event.GetPullRequest())},
+	}
+}
+
+// sendMessage creates a post about the GitHub object in the channel and records it in the post index.
+func (p *Plugin) sendMessage(obj githubObject, content postContent, channel *model.Channel, pinned bool) error {
 	botUserId := p.botUserId
 	if botUserId == nil {
-		return nil, fmt.Errorf("bot user ID is nil")
+		return fmt.Errorf("bot user ID is nil")
 	}
 
-	team, err := p.ensureTeam(*botUserId, teamName)
+	post := &model.Post{
+		IsPinned:  pinned,
+		UserId:    *botUserId,
+		ChannelId: channel.Id,
+	}
+	content.apply(post)
+
+	err := p.client.Post.CreatePost(post)
 	if err != nil {
-		return nil, fmt.Errorf("failed to ensure team %s: %w", teamName, err)
+		return fmt.Errorf("failed to create post in channel %s: %w", channel.Name, err)
 	}
 
-	channel, err := p.client.Channel.GetByName(team.Id, channelName, false)
+	return p.indexPost(obj, post)
+}
+
+// updateMessages rewrites the bot's posts about the object in the channels of the event being handled.
+func (p *Plugin) updateMessages(ctx context.Context, obj githubObject, content postContent) error {
+	posts, err := p.eventPosts(ctx, obj)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get channel by name %s: %w", channelName, err)
+		return fmt.Errorf("failed to find posts for %s: %w", obj.Tag, err)
+	}
+
+	var errs []error
+	for _, post := range posts {
+		if content.matches(post) {
+			continue
+		}
+
+		content.apply(post)
+		if err = p.client.Post.UpdatePost(post); err != nil {
+			errs = append(errs, fmt.Errorf("failed to update post %s for %s: %w", post.Id, obj.Tag, err))
+		}
 	}
 
-	posts, err := p.client.Post.SearchPostsInTeam(team.Id, []*model.SearchParams{{
-		Terms: term,
-	}})
+	return errors.Join(errs...)
+}
+
+// unpinMessages unpins the bot's posts about the object in the channels of the event being
+// handled, regardless of whether the routes still match the object.
+func (p *Plugin) unpinMessages(ctx context.Context, obj githubObject) error {
+	posts, err := p.eventPosts(ctx, obj)
 	if err != nil {
-		return nil, fmt.Errorf("failed to search posts in team %s: %w", teamName, err)
+		return fmt.Errorf("failed to find posts for %s: %w", obj.Tag, err)
 	}
 
-	// Filter posts to only those in the specified channel
-	var filteredPosts []*model.Post
+	var errs []error
 	for _, post := range posts {
-		if post.ChannelId == channel.Id && post.UserId == *botUserId {
-			filteredPosts = append(filteredPosts, post)
+		if !post.IsPinned {
+			continue
+		}
+
+		post.IsPinned = false
+		if err = p.client.Post.UpdatePost(post); err != nil {
+			errs = append(errs, fmt.Errorf("failed to unpin post %s for %s: %w", post.Id, obj.Tag, err))
 		}
 	}
 
-	return filteredPosts, nil
+	// Posts in other channels are still pinned after an event received on a webhook endpoint
+	if len(errs) == 0 && obj.Kind == githubObjectPullRequest && eventEndpoint(ctx) == nil {
+		if err = p.updatePinnedPullRequests(obj.RepoID, nil, []int64{obj.Number}); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
 }
 
-func (p *Plugin) ensureTeam(botUserId, teamName string) (*model.Team, error) {
-	team, err := p.client.Team.GetByName(teamName)
+func (p *Plugin) unpinMessage(obj githubObject, teamName, channelName string) error {
+	channel, err := p.getChannel(teamName, channelName)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get team by name %s: %w", teamName, err)
+		return err
 	}
 
-	foundBotUserInTeam := false
-	page := 0
-out:
-	for {
-		memberList, err := p.client.Team.ListMembers(team.Id, page, 100)
+	post, err := p.getIndexedPost(obj, channel.Id)
+	if err != nil {
+		return fmt.Errorf("failed to find post for %s: %w", obj.Tag, err)
+	}
+
+	if post != nil && post.IsPinned {
+		post.IsPinned = false
+		err = p.client.Post.UpdatePost(post)
 		if err != nil {
-			return nil, fmt.Errorf("failed to list team members: %w", err)
+			return fmt.Errorf("failed to update post in channel %s: %w", channelName, err)
 		}
+	}
 
-		if len(memberList) < 100 {
-			break
+	return nil
+}
+
+func (p *Plugin) getChannel(teamName, channelName string) (*model.Channel, error) {
+	channel, err := p.client.Channel.GetByNameForTeamName(teamName, channelName, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get channel by name %s/%s: %w", teamName, channelName, err)
+	}
+
+	return channel, nil
+}
+
+// joinTeams makes the bot a member of every team that events, CI alerts or digests are posted to.
+// Teams that cannot be joined are logged and skipped, so that a typo in one route does not break
+// the others.
+func (p *Plugin) joinTeams() {
+	botUserId := p.botUserId
+	if botUserId == nil {
+		return
+	}
+
+	config := p.getConfiguration()
+
+	teams := routeTeams(append(slices.Clip(config.routes), webhookEndpointRoutes(config.webhookEndpoints)...))
+	var extraTargets []routeTarget
+	if config.digest != nil {
+		extraTargets = append(extraTargets, config.digest.Targets...)
+	}
+	if config.ciTarget != nil {
+		extraTargets = append(extraTargets, *config.ciTarget)
+	}
+	for _, target := range extraTargets {
+		if !slices.Contains(teams, target.Team) {
+			teams = append(teams, target.Team)
 		}
+	}
 
-		for _, member := range memberList {
-			if member.UserId == botUserId {
-				foundBotUserInTeam = true
-				break out
-			}
+	for _, teamName := range teams {
+		if err := p.ensureTeamMember(*botUserId, teamName); err != nil {
+			p.client.Log.Error("Failed to join team", "team", teamName, "error", err.Error())
 		}
+	}
+}
 
-		page += 1
+func (p *Plugin) ensureTeamMember(botUserId, teamName string) error {
+	team, err := p.client.Team.GetByName(teamName)
+	if err != nil {
+		return fmt.Errorf("failed to get team by name %s: %w", teamName, err)
+	}
+
+	member, err := p.client.Team.GetMember(team.Id, botUserId)
+	if err == nil && member.DeleteAt == 0 {
+		return nil
+	}
+	if err != nil && !errors.Is(err, pluginapi.ErrNotFound) {
+		return fmt.Errorf("failed to get team member: %w", err)
 	}
 
-	if !foundBotUserInTeam {
-		_, err = p.client.Team.CreateMember(team.Id, botUserId)
+	if _, err = p.client.Team.CreateMember(team.Id, botUserId); err != nil {
+		return fmt.Errorf("failed to add bot to team %s: %w", teamName, err)
 	}
 
-	return team, nil
+	return nil
 }
 
-// ServerHTTP handles HTTP requests made to the plugin.
-func (p *Plugin) ServeHTTP(_ *plugin.Context, _ http.ResponseWriter, r *http.Request) {
-	if r.URL.Path == "/github" {
-		println("GitHub event listener called")
-		if p.eventHandler != nil {
-			println("Handling GitHub event request")
-			if err := p.eventHandler.HandleEventRequest(r); err != nil {
-				fmt.Printf("error handling github event request: %v\n", err)
-			}
-		} else {
-			fmt.Println("event handler is nil")
+// handleEventRequest validates the GitHub event in the request and queues it for the event
+// handler, unless the same delivery has already been received. Requests to a webhook endpoint are
+// validated with the endpoint's secret instead of the global ones.
+func (p *Plugin) handleEventRequest(handlers []*githubevents.EventHandler, r *http.Request, endpoint *webhookEndpoint) (*webhookResponse, error) {
+	config := p.getConfiguration()
+
+	if err := p.checkWebhookSource(config, r); err != nil {
+		return nil, err
+	}
+
+	secrets := config.webhookSecrets
+	if endpoint != nil {
+		secrets = endpoint.secrets()
+	} else if len(secrets) == 0 && !config.AllowUnsignedWebhooks {
+		return nil, newWebhookError(http.StatusUnauthorized, "no webhook secret is configured")
+	}
+
+	payload, secret, err := readWebhookPayload(r, secrets, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	// Deliveries signed with a previous secret show whether it can be removed yet
+	if secret != nil {
+		p.client.Log.Info("Validated GitHub delivery signature", "delivery_id", github.DeliveryID(r), "secret", secret.Name)
+	}
+
+	if endpoint != nil {
+		if err = endpoint.checkRepository(github.WebHookType(r), payload); err != nil {
+			return nil, err
 		}
-	} else {
-		fmt.Printf("unknown path: %s\n", r.URL.Path)
 	}
+
+	return p.enqueueEvent(handlers, endpoint, github.DeliveryID(r), github.WebHookType(r), payload)
+}
+
+// ServerHTTP handles HTTP requests made to the plugin.
+func (p *Plugin) ServeHTTP(_ *plugin.Context, w http.ResponseWriter, r *http.Request) {
+	var endpoint *webhookEndpoint
+	if endpointID, found := strings.CutPrefix(r.URL.Path, "/github/"); found {
+		endpoint = p.getConfiguration().getWebhookEndpoint(endpointID)
+	}
+
+	if r.URL.Path != "/github" && endpoint == nil {
+		p.writeWebhookResponse(w, http.StatusNotFound, &webhookResponse{
+			Status: webhookStatusError,
+			Error:  fmt.Sprintf("unknown path: %s", r.URL.Path),
+		})
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		p.writeWebhookResponse(w, http.StatusMethodNotAllowed, &webhookResponse{
+			Status: webhookStatusError,
+			Error:  fmt.Sprintf("method %s is not allowed", r.Method),
+		})
+		return
+	}
+
+	if p.eventHandlers == nil || p.queue == nil {
+		p.writeWebhookResponse(w, http.StatusServiceUnavailable, &webhookResponse{
+			Status: webhookStatusError,
+			Error:  "the plugin is not ready to handle events",
+		})
+		return
+	}
+
+	handlers := p.eventHandlers
+	if endpoint != nil {
+		handlers = p.endpointEventHandlers
+	}
+
+	response, err := p.handleEventRequest(handlers, r, endpoint)
+	if err != nil {
+		status := webhookErrorStatus(err)
+		p.client.Log.Warn("Failed to handle GitHub event request",
+			"delivery_id", github.DeliveryID(r), "event", github.WebHookType(r), "status", status, "error", err.Error())
+
+		p.writeWebhookResponse(w, status, &webhookResponse{
+			Status:     webhookStatusError,
+			DeliveryID: github.DeliveryID(r),
+			Event:      github.WebHookType(r),
+			Error:      err.Error(),
+		})
+		return
+	}
+
+	// Queued events are handled in the background
+	status := http.StatusOK
+	if response.Status == webhookStatusQueued {
+		status = http.StatusAccepted
+	}
+
+	p.writeWebhookResponse(w, status, response)
 }
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-github/v76/github"
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+var testRepo = &github.Repository{
+	ID:    github.Ptr(int64(1)),
+	Name:  github.Ptr("holochain"),
+	Owner: &github.User{Login: github.Ptr("holochain")},
+}
+
+// createIndexedPost creates a post about the object in the channel and records it in the post index.
+func createIndexedPost(t *testing.T, p *Plugin, obj githubObject, channelID string, content postContent, pinned bool) *model.Post {
+	t.Helper()
+
+	if err := p.sendMessage(obj, content, &model.Channel{Id: channelID}, pinned); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	post, err := p.getIndexedPost(obj, channelID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return post
+}
+
+func TestUpdateMessages(t *testing.T) {
+	obj := pullRequestObject(testRepo, &github.PullRequest{Number: github.Ptr(1)})
+	open := textContent(":new: Fix crash\n" + obj.Tag)
+	merged := textContent(":tada: Fix crash\n" + obj.Tag)
+
+	for name, tc := range map[string]struct {
+		channels []string
+		deleted  []string
+	}{
+		"one channel":      {channels: []string{"a"}},
+		"several channels": {channels: []string{"a", "b"}},
+		"deleted post":     {channels: []string{"a", "b"}, deleted: []string{"b"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			p, api := newTestPlugin()
+
+			posts := map[string]*model.Post{}
+			for _, channelID := range tc.channels {
+				posts[channelID] = createIndexedPost(t, p, obj, channelID, open, true)
+			}
+			for _, channelID := range tc.deleted {
+				delete(api.posts, posts[channelID].Id)
+			}
+
+			if err := p.updateMessages(context.Background(), obj, merged); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			for channelID, post := range posts {
+				updated := api.post(post.Id)
+				if updated == nil {
+					// Deleted posts are dropped from the index rather than recreated
+					if indexed, err := p.getIndexedPost(obj, channelID); err != nil || indexed != nil {
+						t.Errorf("expected the deleted post in channel %s to be unindexed, got %v, %v", channelID, indexed, err)
+					}
+					continue
+				}
+				if updated.Message != merged.Message {
+					t.Errorf("expected %q in channel %s, got %q", merged.Message, channelID, updated.Message)
+				}
+				if !updated.IsPinned {
+					t.Errorf("expected the post in channel %s to stay pinned", channelID)
+				}
+			}
+		})
+	}
+}
+
+func TestPullRequestPinning(t *testing.T) {
+	pullRequest := &github.PullRequest{Number: github.Ptr(1), Title: github.Ptr("Fix crash"), State: github.Ptr("open")}
+	draft := &github.PullRequest{Number: github.Ptr(1), Title: github.Ptr("Fix crash"), State: github.Ptr("open"), Draft: github.Ptr(true)}
+
+	for name, tc := range map[string]struct {
+		existing       *github.PullRequest
+		existingPinned bool
+		action         string
+		pullRequest    *github.PullRequest
+		expectedPinned bool
+	}{
+		"opened":                     {action: "opened", pullRequest: pullRequest, expectedPinned: true},
+		"converted to draft unpins":  {existing: pullRequest, existingPinned: true, action: "converted_to_draft", pullRequest: draft},
+		"reopened re-pins":           {existing: pullRequest, action: "reopened", pullRequest: pullRequest, expectedPinned: true},
+		"ready for review re-pins":   {existing: draft, action: "ready_for_review", pullRequest: pullRequest, expectedPinned: true},
+		"already pinned stays once":  {existing: pullRequest, existingPinned: true, action: "opened", pullRequest: pullRequest, expectedPinned: true},
+		"closed unpins":              {existing: pullRequest, existingPinned: true, action: "closed", pullRequest: pullRequest},
+		"converted to draft no post": {action: "converted_to_draft", pullRequest: draft},
+	} {
+		t.Run(name, func(t *testing.T) {
+			p, api := newTestPlugin()
+			api.addChannel("team", "prs", "channel")
+
+			if tc.existing != nil {
+				event := &github.PullRequestEvent{Action: github.Ptr("opened"), Repo: testRepo, PullRequest: tc.existing}
+				obj := pullRequestObject(testRepo, tc.existing)
+				createIndexedPost(t, p, obj, "channel", p.pullRequestContent(event, obj), tc.existingPinned)
+			}
+
+			event := &github.PullRequestEvent{Action: github.Ptr(tc.action), Repo: testRepo, PullRequest: tc.pullRequest}
+			obj := pullRequestObject(testRepo, tc.pullRequest)
+			var err error
+			switch tc.action {
+			case "converted_to_draft", "closed":
+				err = p.unpinMessages(context.Background(), obj)
+			default:
+				err = p.ensurePullRequestPinned(event, "team", "prs")
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(api.posts) > 1 {
+				t.Fatalf("expected at most one post, got %d", len(api.posts))
+			}
+			post, err := p.getIndexedPost(obj, "channel")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if post == nil {
+				if tc.expectedPinned {
+					t.Fatal("expected a pinned post, got none")
+				}
+				return
+			}
+			if post.IsPinned != tc.expectedPinned {
+				t.Errorf("expected pinned %v, got %v", tc.expectedPinned, post.IsPinned)
+			}
+			if tc.expectedPinned && !p.pullRequestContent(event, obj).matches(post) {
+				t.Error("expected the pinned post to show the pull request's current state")
+			}
+		})
+	}
+}
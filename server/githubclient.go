@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v76/github"
+)
+
+// newGitHubClient creates a client for the GitHub REST API from the configured credentials. A
+// GitHub App installation takes precedence over a personal access token. Without credentials
+// requests are anonymous, so only public repositories can be read, subject to GitHub's rate
+// limit for anonymous requests.
+func newGitHubClient(config *Configuration) (*github.Client, error) {
+	if config.GitHubAppID != 0 || config.GitHubAppInstallationID != 0 || strings.TrimSpace(config.GitHubAppPrivateKey) != "" {
+		if config.GitHubAppID == 0 || config.GitHubAppInstallationID == 0 {
+			return nil, errors.New("both the GitHub App ID and installation ID must be set")
+		}
+
+		key, err := parsePrivateKey(config.GitHubAppPrivateKey)
+		if err != nil {
+			return nil, err
+		}
+
+		transport := &appInstallationTransport{
+			appID:          config.GitHubAppID,
+			installationID: config.GitHubAppInstallationID,
+			key:            key,
+			base:           http.DefaultTransport,
+		}
+
+		return github.NewClient(&http.Client{Transport: transport}), nil
+	}
+
+	if token := strings.TrimSpace(config.GitHubToken); token != "" {
+		return github.NewClient(nil).WithAuthToken(token), nil
+	}
+
+	return github.NewClient(nil), nil
+}
+
+// hasGitHubCredentials reports whether requests to GitHub are authenticated.
+func (c *Configuration) hasGitHubCredentials() bool {
+	return c.GitHubAppID != 0 || strings.TrimSpace(c.GitHubToken) != ""
+}
+
+// githubClient returns the client for the GitHub REST API built from the active configuration.
+func (p *Plugin) githubClient() *github.Client {
+	if client := p.getConfiguration().github; client != nil {
+		return client
+	}
+
+	return github.NewClient(nil)
+}
+
+func parsePrivateKey(data string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(strings.TrimSpace(data)))
+	if block == nil {
+		return nil, errors.New("the GitHub App private key is not PEM encoded")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the GitHub App private key: %w", err)
+	}
+
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("the GitHub App private key is not an RSA key")
+	}
+
+	return rsaKey, nil
+}
+
+// appInstallationTransport authenticates requests as a GitHub App installation. Installation
+// tokens are minted with a JWT signed by the app's private key and cached until shortly before
+// they expire.
+type appInstallationTransport struct {
+	appID          int64
+	installationID int64
+	key            *rsa.PrivateKey
+	base           http.RoundTripper
+
+	mu        sync.Mutex
+	token     string
+	expiresAt time.Time
+}
+
+func (t *appInstallationTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.installationToken(req.Context())
+	if err != nil {
+		return nil, err
+	}
+
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "token "+token)
+
+	return t.base.RoundTrip(req)
+}
+
+func (t *appInstallationTransport) installationToken(ctx context.Context) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// Refresh early, so that a token does not expire while a request is in flight
+	if t.token != "" && time.Until(t.expiresAt) > 5*time.Minute {
+		return t.token, nil
+	}
+
+	jwt, err := appJWT(t.appID, t.key, time.Now())
+	if err != nil {
+		return "", err
+	}
+
+	appClient := github.NewClient(&http.Client{Transport: t.base}).WithAuthToken(jwt)
+	token, _, err := appClient.Apps.CreateInstallationToken(ctx, t.installationID, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create GitHub App installation token: %w", err)
+	}
+
+	t.token = token.GetToken()
+	t.expiresAt = token.GetExpiresAt().Time
+
+	return t.token, nil
+}
+
+// appJWT creates the JSON Web Token that authenticates as the GitHub App itself, see
+// https://docs.github.com/en/apps/creating-github-apps/authenticating-with-a-github-app/generating-a-json-web-token-jwt-for-a-github-app
+func appJWT(appID int64, key *rsa.PrivateKey, now time.Time) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+
+	claims, err := json.Marshal(map[string]any{
+		// Backdated to allow for clock drift between this server and GitHub
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": strconv.FormatInt(appID, 10),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign GitHub App JWT: %w", err)
+	}
+
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
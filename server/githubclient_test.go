@@ -0,0 +1,116 @@
+package main
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAppJWT(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	now := time.Unix(1700000000, 0)
+	jwt, err := appJWT(12345, key, now)
+	if err != nil {
+		t.Fatalf("failed to create JWT: %v", err)
+	}
+
+	parts := strings.Split(jwt, ".")
+	if len(parts) != 3 {
+		t.Fatalf("expected 3 JWT parts, got %d", len(parts))
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		t.Fatalf("failed to decode signature: %v", err)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err = rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], signature); err != nil {
+		t.Errorf("invalid signature: %v", err)
+	}
+
+	claimsJSON, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		t.Fatalf("failed to decode claims: %v", err)
+	}
+	var claims struct {
+		IssuedAt  int64  `json:"iat"`
+		ExpiresAt int64  `json:"exp"`
+		Issuer    string `json:"iss"`
+	}
+	if err = json.Unmarshal(claimsJSON, &claims); err != nil {
+		t.Fatalf("failed to unmarshal claims: %v", err)
+	}
+
+	if claims.Issuer != "12345" {
+		t.Errorf("expected issuer 12345, got %s", claims.Issuer)
+	}
+	if claims.IssuedAt != now.Unix()-60 {
+		t.Errorf("expected iat %d, got %d", now.Unix()-60, claims.IssuedAt)
+	}
+	if claims.ExpiresAt-claims.IssuedAt > 10*60 {
+		t.Errorf("expected the JWT to expire within 10 minutes, got %d seconds", claims.ExpiresAt-claims.IssuedAt)
+	}
+}
+
+func TestParsePrivateKey(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+
+	for name, tc := range map[string]struct {
+		data        string
+		expectedErr bool
+	}{
+		"PKCS#1": {
+			data: string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})),
+		},
+		"PKCS#8": {
+			data: string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8})),
+		},
+		"surrounding whitespace": {
+			data: "\n  " + string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})),
+		},
+		"not PEM": {
+			data:        "not a key",
+			expectedErr: true,
+		},
+		"not a key": {
+			data:        string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte("garbage")})),
+			expectedErr: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			parsed, err := parsePrivateKey(tc.data)
+			if tc.expectedErr {
+				if err == nil {
+					t.Error("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !parsed.Equal(key) {
+				t.Error("parsed key does not match")
+			}
+		})
+	}
+}
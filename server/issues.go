@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/cbrgm/githubevents/v2/githubevents"
+	"github.com/google/go-github/v76/github"
+)
+
+// Issue lifecycle events can either edit the issue's post, be posted as a reply to it, or be ignored.
+const (
+	issueActionEdit  = "edit"
+	issueActionReply = "reply"
+	issueActionOff   = "off"
+)
+
+// issueLifecycleAction describes how an issue event action is handled.
+type issueLifecycleAction struct {
+	// mode is the configured handling, with an empty mode falling back to defaultMode.
+	mode        string
+	defaultMode string
+	register    func(callbacks ...githubevents.IssuesEventHandleFunc)
+	// edit returns the new text of the issue's post, reply the text of the reply.
+	edit  func(event *github.IssuesEvent, obj githubObject) postContent
+	reply func(event *github.IssuesEvent) string
+}
+
+// registerIssueLifecycleHandlers handles the configured issue actions after an issue was opened.
+// Events for issues that were never posted are ignored.
+func (p *Plugin) registerIssueLifecycleHandlers(eventHandler *githubevents.EventHandler, config *Configuration) {
+	for _, action := range []issueLifecycleAction{
+		{
+			mode:        config.IssueClosedMode,
+			defaultMode: issueActionEdit,
+			register:    eventHandler.OnIssuesEventClosed,
+			edit:        p.issueContent,
+			reply: func(event *github.IssuesEvent) string {
+				if event.GetIssue().GetStateReason() == "not_planned" {
+					return fmt.Sprintf(":no_entry_sign: Closed as not planned by %s", event.GetSender().GetLogin())
+				}
+				return fmt.Sprintf(":white_check_mark: Closed as completed by %s", event.GetSender().GetLogin())
+			},
+		},
+		{
+			mode:        config.IssueReopenedMode,
+			defaultMode: issueActionEdit,
+			register:    eventHandler.OnIssuesEventReopened,
+			edit:        p.issueContent,
+			reply: func(event *github.IssuesEvent) string {
+				return fmt.Sprintf(":arrows_counterclockwise: Reopened by %s", event.GetSender().GetLogin())
+			},
+		},
+		{
+			mode:        config.IssueLabeledMode,
+			defaultMode: issueActionOff,
+			register:    eventHandler.OnIssuesEventLabeled,
+			edit:        p.issueContent,
+			reply: func(event *github.IssuesEvent) string {
+				return fmt.Sprintf(":label: %s added the `%s` label", event.GetSender().GetLogin(), event.GetLabel().GetName())
+			},
+		},
+		{
+			mode:        config.IssueUnlabeledMode,
+			defaultMode: issueActionOff,
+			register:    eventHandler.OnIssuesEventUnlabeled,
+			edit:        p.issueContent,
+			reply: func(event *github.IssuesEvent) string {
+				return fmt.Sprintf(":label: %s removed the `%s` label", event.GetSender().GetLogin(), event.GetLabel().GetName())
+			},
+		},
+		{
+			mode:        config.IssueAssignedMode,
+			defaultMode: issueActionOff,
+			register:    eventHandler.OnIssuesEventAssigned,
+			edit:        p.issueContent,
+			reply: func(event *github.IssuesEvent) string {
+				return fmt.Sprintf(":bust_in_silhouette: %s assigned %s", event.GetSender().GetLogin(), p.mention(event.GetAssignee().GetLogin()))
+			},
+		},
+		{
+			mode:        config.IssueTransferredMode,
+			defaultMode: issueActionOff,
+			register:    eventHandler.OnIssuesEventTransferred,
+			edit: func(event *github.IssuesEvent, obj githubObject) postContent {
+				// The issue's old URL redirects to the issue in its new repository
+				issue := event.GetIssue()
+				return textContent(fmt.Sprintf(":truck: Transferred: %s\n%s\n%s", issue.GetTitle(), issue.GetHTMLURL(), obj.Tag))
+			},
+			reply: func(event *github.IssuesEvent) string {
+				return fmt.Sprintf(":truck: Transferred to another repository by %s", event.GetSender().GetLogin())
+			},
+		},
+		{
+			mode:        config.IssueMilestonedMode,
+			defaultMode: issueActionOff,
+			register:    eventHandler.OnIssuesEventMilestoned,
+			edit:        p.issueContent,
+			reply: func(event *github.IssuesEvent) string {
+				milestone := event.GetIssue().GetMilestone()
+				return fmt.Sprintf(":triangular_flag_on_post: %s added this to the [%s](%s) milestone",
+					event.GetSender().GetLogin(), milestone.GetTitle(), milestone.GetHTMLURL())
+			},
+		},
+		{
+			mode:        config.IssueDeletedMode,
+			defaultMode: issueActionOff,
+			register:    eventHandler.OnIssuesEventDeleted,
+			edit: func(event *github.IssuesEvent, obj githubObject) postContent {
+				return textContent(fmt.Sprintf(":wastebasket: ~~%s~~\n%s", event.GetIssue().GetTitle(), obj.Tag))
+			},
+			reply: func(event *github.IssuesEvent) string {
+				return fmt.Sprintf(":wastebasket: Deleted by %s", event.GetSender().GetLogin())
+			},
+		},
+	} {
+		mode := strings.TrimSpace(action.mode)
+		if mode == "" {
+			mode = action.defaultMode
+		}
+
+		switch mode {
+		case issueActionEdit:
+			action.register(func(ctx context.Context, deliveryID string, eventName string, event *github.IssuesEvent) error {
+				obj := issueObject(event.GetRepo(), event.GetIssue())
+
+				return p.updateMessages(ctx, obj, action.edit(event, obj))
+			})
+		case issueActionReply:
+			action.register(func(ctx context.Context, deliveryID string, eventName string, event *github.IssuesEvent) error {
+				obj := issueObject(event.GetRepo(), event.GetIssue())
+
+				return p.replyToPosts(ctx, deliveryID, obj, action.reply(event))
+			})
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cbrgm/githubevents/v2/githubevents"
+	"github.com/google/go-github/v76/github"
+)
+
+func TestIssueLifecycleModes(t *testing.T) {
+	for name, tc := range map[string]struct {
+		action   string
+		config   Configuration
+		expected string
+	}{
+		"closed defaults to edit":       {action: "closed", expected: issueActionEdit},
+		"reopened defaults to edit":     {action: "reopened", expected: issueActionEdit},
+		"labeled defaults to off":       {action: "labeled", expected: issueActionOff},
+		"unlabeled defaults to off":     {action: "unlabeled", expected: issueActionOff},
+		"assigned defaults to off":      {action: "assigned", expected: issueActionOff},
+		"transferred defaults to off":   {action: "transferred", expected: issueActionOff},
+		"milestoned defaults to off":    {action: "milestoned", expected: issueActionOff},
+		"deleted defaults to off":       {action: "deleted", expected: issueActionOff},
+		"closed as reply":               {action: "closed", config: Configuration{IssueClosedMode: "reply"}, expected: issueActionReply},
+		"closed turned off":             {action: "closed", config: Configuration{IssueClosedMode: "off"}, expected: issueActionOff},
+		"labeled as edit":               {action: "labeled", config: Configuration{IssueLabeledMode: "edit"}, expected: issueActionEdit},
+		"mode with surrounding spaces":  {action: "assigned", config: Configuration{IssueAssignedMode: " reply "}, expected: issueActionReply},
+		"other action mode is not used": {action: "reopened", config: Configuration{IssueClosedMode: "off"}, expected: issueActionEdit},
+	} {
+		t.Run(name, func(t *testing.T) {
+			p, api := newTestPlugin()
+
+			issue := &github.Issue{Number: github.Ptr(1), Title: github.Ptr("Crash on start"), State: github.Ptr("open")}
+			obj := issueObject(testRepo, issue)
+			post := createIndexedPost(t, p, obj, "channel", textContent("Crash on start\n"+obj.Tag), false)
+
+			eventHandler := githubevents.New("")
+			p.registerIssueLifecycleHandlers(eventHandler, &tc.config)
+
+			event := &github.IssuesEvent{
+				Action: github.Ptr(tc.action),
+				Repo:   testRepo,
+				Issue:  issue,
+				Sender: &github.User{Login: github.Ptr("octocat")},
+			}
+			if err := eventHandler.HandleEvent(context.Background(), "", "issues", event); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			mode := issueActionOff
+			if api.post(post.Id).Message != post.Message {
+				mode = issueActionEdit
+			}
+			if len(api.posts) > 1 {
+				mode = issueActionReply
+			}
+			if mode != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, mode)
+			}
+		})
+	}
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v76/github"
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+const (
+	githubLinkKeyPrefix     = "link_gh_"
+	mattermostLinkKeyPrefix = "link_mm_"
+
+	githubDeviceCodeURL  = "https://github.com/login/device/code"
+	githubAccessTokenURL = "https://github.com/login/oauth/access_token"
+)
+
+func githubLinkKey(login string) string {
+	return githubLinkKeyPrefix + strings.ToLower(login)
+}
+
+func mattermostLinkKey(userID string) string {
+	return mattermostLinkKeyPrefix + userID
+}
+
+// getLinkedUserID returns the ID of the Mattermost user that linked the GitHub login, or an
+// empty string if nobody did.
+func (p *Plugin) getLinkedUserID(login string) (string, error) {
+	var userID string
+	if err := p.client.KV.Get(githubLinkKey(login), &userID); err != nil {
+		return "", fmt.Errorf("failed to get account link for %s: %w", login, err)
+	}
+
+	return userID, nil
+}
+
+// getLinkedLogin returns the GitHub login that the Mattermost user linked, or an empty string.
+func (p *Plugin) getLinkedLogin(userID string) (string, error) {
+	var login string
+	if err := p.client.KV.Get(mattermostLinkKey(userID), &login); err != nil {
+		return "", fmt.Errorf("failed to get account link for user %s: %w", userID, err)
+	}
+
+	return login, nil
+}
+
+// linkAccounts links the GitHub login to the Mattermost user, replacing any earlier link of
+// either account.
+func (p *Plugin) linkAccounts(userID, login string) error {
+	if err := p.unlinkAccounts(userID); err != nil {
+		return err
+	}
+
+	previousUserID, err := p.getLinkedUserID(login)
+	if err != nil {
+		return err
+	}
+	if previousUserID != "" {
+		if err = p.client.KV.Delete(mattermostLinkKey(previousUserID)); err != nil {
+			return fmt.Errorf("failed to remove previous account link for %s: %w", login, err)
+		}
+	}
+
+	if _, err = p.client.KV.Set(githubLinkKey(login), userID); err != nil {
+		return fmt.Errorf("failed to link %s: %w", login, err)
+	}
+	if _, err = p.client.KV.Set(mattermostLinkKey(userID), login); err != nil {
+		return fmt.Errorf("failed to link %s: %w", login, err)
+	}
+
+	return nil
+}
+
+// unlinkAccounts removes the Mattermost user's link to a GitHub account, if there is one.
+func (p *Plugin) unlinkAccounts(userID string) error {
+	login, err := p.getLinkedLogin(userID)
+	if err != nil || login == "" {
+		return err
+	}
+
+	if err = p.client.KV.Delete(githubLinkKey(login)); err != nil {
+		return fmt.Errorf("failed to unlink %s: %w", login, err)
+	}
+	if err = p.client.KV.Delete(mattermostLinkKey(userID)); err != nil {
+		return fmt.Errorf("failed to unlink %s: %w", login, err)
+	}
+
+	return nil
+}
+
+// deviceCode is GitHub's response to starting the OAuth device flow, see
+// https://docs.github.com/en/apps/oauth-apps/building-oauth-apps/authorizing-oauth-apps#device-flow
+type deviceCode struct {
+	DeviceCode      string `json:"device_code"`
+	UserCode        string `json:"user_code"`
+	VerificationURI string `json:"verification_uri"`
+	ExpiresIn       int    `json:"expires_in"`
+	Interval        int    `json:"interval"`
+}
+
+type accessTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	Error       string `json:"error"`
+	Interval    int    `json:"interval"`
+}
+
+func postGitHubOAuthForm(ctx context.Context, endpoint string, form url.Values, response any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s from %s", resp.Status, endpoint)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(response)
+}
+
+// startAccountLink starts the OAuth device flow for the Mattermost user. The user is shown a code
+// to enter on GitHub, and the accounts are linked in the background once they have done so.
+func (p *Plugin) startAccountLink(userID string) (*deviceCode, error) {
+	clientID := strings.TrimSpace(p.getConfiguration().GitHubOAuthClientID)
+	if clientID == "" {
+		return nil, errors.New("account linking is not configured, ask a system admin to set the GitHub OAuth client ID")
+	}
+
+	var code deviceCode
+	if err := postGitHubOAuthForm(context.Background(), githubDeviceCodeURL, url.Values{"client_id": {clientID}}, &code); err != nil {
+		return nil, fmt.Errorf("failed to start GitHub device flow: %w", err)
+	}
+	if code.DeviceCode == "" {
+		return nil, errors.New("GitHub did not return a device code, check that device flow is enabled for the OAuth app")
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(code.ExpiresIn)*time.Second)
+		defer cancel()
+
+		message := p.completeAccountLink(ctx, clientID, userID, &code)
+		if err := p.client.Post.DM(*p.botUserId, userID, &model.Post{Message: message}); err != nil {
+			p.client.Log.Error("Failed to send account link result", "user_id", userID, "error", err.Error())
+		}
+	}()
+
+	return &code, nil
+}
+
+// completeAccountLink waits for the user to authorize the device code, and links the GitHub
+// account it was authorized with. It returns a message describing the outcome for the user.
+func (p *Plugin) completeAccountLink(ctx context.Context, clientID, userID string, code *deviceCode) string {
+	token, err := pollAccessToken(ctx, clientID, code)
+	if err != nil {
+		p.client.Log.Warn("GitHub account link failed", "user_id", userID, "error", err.Error())
+		return fmt.Sprintf("Linking your GitHub account failed: %s", err)
+	}
+
+	// The token is only used to find out who authorized it, and is not stored
+	user, _, err := github.NewClient(nil).WithAuthToken(token).Users.Get(ctx, "")
+	if err != nil {
+		p.client.Log.Warn("Failed to get GitHub user for account link", "user_id", userID, "error", err.Error())
+		return "Linking your GitHub account failed: GitHub did not return your account."
+	}
+
+	if err = p.linkAccounts(userID, user.GetLogin()); err != nil {
+		p.client.Log.Error("Failed to store account link", "user_id", userID, "error", err.Error())
+		return "Linking your GitHub account failed, please try again."
+	}
+
+	return fmt.Sprintf("Your Mattermost account is now linked to GitHub account [%s](%s).", user.GetLogin(), user.GetHTMLURL())
+}
+
+func pollAccessToken(ctx context.Context, clientID string, code *deviceCode) (string, error) {
+	interval := time.Duration(code.Interval) * time.Second
+	form := url.Values{
+		"client_id":   {clientID},
+		"device_code": {code.DeviceCode},
+		"grant_type":  {"urn:ietf:params:oauth:grant-type:device_code"},
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return "", errors.New("the code expired before it was entered")
+		case <-time.After(interval):
+		}
+
+		var response accessTokenResponse
+		if err := postGitHubOAuthForm(ctx, githubAccessTokenURL, form, &response); err != nil {
+			return "", err
+		}
+
+		switch response.Error {
+		case "":
+			return response.AccessToken, nil
+		case "authorization_pending":
+		case "slow_down":
+			if response.Interval > 0 {
+				interval = time.Duration(response.Interval) * time.Second
+			} else {
+				interval += 5 * time.Second
+			}
+		case "access_denied":
+			return "", errors.New("access was denied on GitHub")
+		case "expired_token":
+			return "", errors.New("the code expired before it was entered")
+		default:
+			return "", fmt.Errorf("GitHub returned %s", response.Error)
+		}
+	}
+}
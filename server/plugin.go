@@ -7,6 +7,7 @@ import (
 	"github.com/mattermost/mattermost/server/public/model"
 	"github.com/mattermost/mattermost/server/public/plugin"
 	"github.com/mattermost/mattermost/server/public/pluginapi"
+	"github.com/mattermost/mattermost/server/public/pluginapi/cluster"
 	"github.com/pkg/errors"
 )
 
@@ -26,7 +27,27 @@ type Plugin struct {
 
 	botUserId *string
 
-	eventHandler *githubevents.EventHandler
+	// eventHandlers handle GitHub events, one for each part of the plugin, so that a part that
+	// fails can be retried on its own. endpointEventHandlers are the parts that handle events
+	// received on webhook endpoints.
+	eventHandlers         []*githubevents.EventHandler
+	endpointEventHandlers []*githubevents.EventHandler
+
+	// postIndexMigrated is closed once the post index migration has finished.
+	postIndexMigrated chan struct{}
+
+	// queue hands received GitHub events to the workers that handle them.
+	queue *eventQueue
+
+	// githubHookRanges caches the source ranges of webhook deliveries published by GitHub.
+	githubHookRanges githubHookRanges
+
+	// jobLock synchronizes rescheduling of the background jobs.
+	jobLock sync.Mutex
+
+	reconcileJob     *cluster.Job
+	digestJob        *cluster.Job
+	staleReminderJob *cluster.Job
 }
 
 // OnActivate is invoked when the plugin is activated. If an error is returned, the plugin will be deactivated.
@@ -45,12 +66,60 @@ func (p *Plugin) OnActivate() error {
 
 	// Store the bot user ID for later use.
 	p.botUserId = &botUserId
+	p.joinTeams()
+
+	// Events are queued while existing posts are added to the post index
+	p.postIndexMigrated = make(chan struct{})
+	go p.migratePostIndex()
+
+	p.queue = newEventQueue(p)
+
+	if err = p.registerCommands(); err != nil {
+		return err
+	}
+
+	if err = p.scheduleReconcileJob(); err != nil {
+		return err
+	}
+
+	if err = p.scheduleDigestJob(); err != nil {
+		return err
+	}
+
+	if err = p.scheduleStaleReminderJob(); err != nil {
+		return err
+	}
 
 	return nil
 }
 
 // OnDeactivate is invoked when the plugin is deactivated.
 func (p *Plugin) OnDeactivate() error {
+	if p.queue != nil {
+		p.queue.close()
+	}
+
+	p.jobLock.Lock()
+	defer p.jobLock.Unlock()
+
+	if p.reconcileJob != nil {
+		if err := p.reconcileJob.Close(); err != nil {
+			return errors.Wrap(err, "failed to stop reconcile job")
+		}
+	}
+
+	if p.digestJob != nil {
+		if err := p.digestJob.Close(); err != nil {
+			return errors.Wrap(err, "failed to stop digest job")
+		}
+	}
+
+	if p.staleReminderJob != nil {
+		if err := p.staleReminderJob.Close(); err != nil {
+			return errors.Wrap(err, "failed to stop stale reminder job")
+		}
+	}
+
 	return nil
 }
 
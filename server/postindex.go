@@ -0,0 +1,425 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-github/v76/github"
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/pluginapi"
+	"github.com/mattermost/mattermost/server/public/pluginapi/cluster"
+)
+
+const (
+	postIndexKeyPrefix       = "post_"
+	legacyPostIndexKeyPrefix = "legacy_"
+	postIndexMigrationKey    = "migration_post_index_v1"
+)
+
+type githubObjectKind string
+
+const (
+	githubObjectIssue       githubObjectKind = "issue"
+	githubObjectPullRequest githubObjectKind = "pr"
+	githubObjectRelease     githubObjectKind = "release"
+)
+
+// githubObject identifies an issue, pull request or release on GitHub independently of the
+// repository's current owner or name, which can both change over the lifetime of the object.
+type githubObject struct {
+	RepoID int64
+	Kind   githubObjectKind
+	// Number is the issue or pull request number, or the release ID.
+	Number int64
+	// Tag is the hashtag appended to posts about this object, so that they can be found with search.
+	Tag string
+	// LegacyTag is the hashtag that posts about this object carried before the post index existed,
+	// under which the migration recorded them.
+	LegacyTag string
+}
+
+// repoOwnerName is the owner part of the hashtags. Webhook payloads carry the owner's name, while
+// the REST API only returns the login.
+func repoOwnerName(repo *github.Repository) string {
+	if name := repo.GetOwner().GetName(); name != "" {
+		return name
+	}
+
+	return repo.GetOwner().GetLogin()
+}
+
+// legacyTag is the hashtag that was appended to posts before the post index existed. It always used
+// the owner's name, which is empty unless the owner has set one, so it must not fall back to the login.
+func legacyTag(repo *github.Repository, id string) string {
+	return fmt.Sprintf("#%s.%s.%s", repo.GetOwner().GetName(), repo.GetName(), id)
+}
+
+func issueObject(repo *github.Repository, issue *github.Issue) githubObject {
+	return githubObject{
+		RepoID:    repo.GetID(),
+		Kind:      githubObjectIssue,
+		Number:    int64(issue.GetNumber()),
+		Tag:       fmt.Sprintf("#%s.%s.%d", repoOwnerName(repo), repo.GetName(), issue.GetNumber()),
+		LegacyTag: legacyTag(repo, strconv.Itoa(issue.GetNumber())),
+	}
+}
+
+func pullRequestObject(repo *github.Repository, pullRequest *github.PullRequest) githubObject {
+	return githubObject{
+		RepoID:    repo.GetID(),
+		Kind:      githubObjectPullRequest,
+		Number:    int64(pullRequest.GetNumber()),
+		Tag:       fmt.Sprintf("#%s.%s.%d", repoOwnerName(repo), repo.GetName(), pullRequest.GetNumber()),
+		LegacyTag: legacyTag(repo, strconv.Itoa(pullRequest.GetNumber())),
+	}
+}
+
+func releaseObject(repo *github.Repository, release *github.RepositoryRelease) githubObject {
+	return githubObject{
+		RepoID:    repo.GetID(),
+		Kind:      githubObjectRelease,
+		Number:    release.GetID(),
+		Tag:       fmt.Sprintf("#%s.%s.%s", repoOwnerName(repo), repo.GetName(), release.GetTagName()),
+		LegacyTag: legacyTag(repo, release.GetTagName()),
+	}
+}
+
+func (o githubObject) key() string {
+	return fmt.Sprintf("%s%d", objectKeyPrefix(o.RepoID, o.Kind), o.Number)
+}
+
+// objectKeyPrefix is the prefix of the post index keys of all objects of a kind in a repository.
+func objectKeyPrefix(repoID int64, kind githubObjectKind) string {
+	return fmt.Sprintf("%s%d_%s_", postIndexKeyPrefix, repoID, kind)
+}
+
+// legacyTagKey is the key under which posts found by the post index migration are stored. Those
+// posts only carry a hashtag, so the repository ID is not known until the next event for them.
+func legacyTagKey(tag string) string {
+	return fmt.Sprintf("%s%x", legacyPostIndexKeyPrefix, sha1.Sum([]byte(strings.ToLower(tag))))
+}
+
+// postIndexEntry maps a channel ID to the ID of the bot's post about a GitHub object in that channel.
+type postIndexEntry map[string]string
+
+func decodePostIndexEntry(data []byte) (postIndexEntry, error) {
+	entry := postIndexEntry{}
+	if len(data) == 0 {
+		return entry, nil
+	}
+
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, fmt.Errorf("failed to decode post index entry: %w", err)
+	}
+
+	return entry, nil
+}
+
+// getIndexedPost returns the bot's post about the object in the given channel, or nil if there
+// is none. Entries pointing at posts that have since been deleted are dropped.
+func (p *Plugin) getIndexedPost(obj githubObject, channelID string) (*model.Post, error) {
+	var entry postIndexEntry
+	if err := p.client.KV.Get(obj.key(), &entry); err != nil {
+		return nil, fmt.Errorf("failed to get post index entry for %s: %w", obj.Tag, err)
+	}
+
+	postID, ok := entry[channelID]
+	if !ok {
+		var err error
+		postID, err = p.adoptLegacyPost(obj, channelID)
+		if err != nil {
+			return nil, err
+		}
+		if postID == "" {
+			return nil, nil
+		}
+	}
+
+	post, err := p.client.Post.GetPost(postID)
+	if errors.Is(err, pluginapi.ErrNotFound) || (err == nil && post.DeleteAt != 0) {
+		// The post was removed from Mattermost, forget about it so that a new one can be created
+		return nil, p.unindexPost(obj, channelID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get post %s: %w", postID, err)
+	}
+
+	return post, nil
+}
+
+// getIndexedPosts returns the bot's posts about the object in every channel it was posted to.
+func (p *Plugin) getIndexedPosts(obj githubObject) ([]*model.Post, error) {
+	var entry, legacy postIndexEntry
+	if err := p.client.KV.Get(obj.key(), &entry); err != nil {
+		return nil, fmt.Errorf("failed to get post index entry for %s: %w", obj.Tag, err)
+	}
+	if err := p.client.KV.Get(legacyTagKey(obj.LegacyTag), &legacy); err != nil {
+		return nil, fmt.Errorf("failed to get legacy post index entry for %s: %w", obj.Tag, err)
+	}
+
+	var channelIDs []string
+	for channelID := range entry {
+		channelIDs = append(channelIDs, channelID)
+	}
+	for channelID := range legacy {
+		if _, ok := entry[channelID]; !ok {
+			channelIDs = append(channelIDs, channelID)
+		}
+	}
+
+	var posts []*model.Post
+	for _, channelID := range channelIDs {
+		post, err := p.getIndexedPost(obj, channelID)
+		if err != nil {
+			return nil, err
+		}
+		if post != nil {
+			posts = append(posts, post)
+		}
+	}
+
+	return posts, nil
+}
+
+// adoptLegacyPost moves a post found by the migration from the hashtag keyed index to the
+// object keyed index, returning its ID if there was one for the channel.
+func (p *Plugin) adoptLegacyPost(obj githubObject, channelID string) (string, error) {
+	legacyKey := legacyTagKey(obj.LegacyTag)
+
+	var legacy postIndexEntry
+	if err := p.client.KV.Get(legacyKey, &legacy); err != nil {
+		return "", fmt.Errorf("failed to get legacy post index entry for %s: %w", obj.Tag, err)
+	}
+
+	postID, ok := legacy[channelID]
+	if !ok {
+		return "", nil
+	}
+
+	if err := p.indexPostID(obj, channelID, postID); err != nil {
+		return "", err
+	}
+
+	err := p.client.KV.SetAtomicWithRetries(legacyKey, func(oldValue []byte) (any, error) {
+		entry, err := decodePostIndexEntry(oldValue)
+		if err != nil {
+			return nil, err
+		}
+
+		delete(entry, channelID)
+		if len(entry) == 0 {
+			return nil, nil
+		}
+
+		return entry, nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to remove legacy post index entry for %s: %w", obj.Tag, err)
+	}
+
+	return postID, nil
+}
+
+// indexPost records the post as the bot's post about the object in the post's channel.
+func (p *Plugin) indexPost(obj githubObject, post *model.Post) error {
+	return p.indexPostID(obj, post.ChannelId, post.Id)
+}
+
+func (p *Plugin) indexPostID(obj githubObject, channelID, postID string) error {
+	err := p.client.KV.SetAtomicWithRetries(obj.key(), func(oldValue []byte) (any, error) {
+		entry, err := decodePostIndexEntry(oldValue)
+		if err != nil {
+			return nil, err
+		}
+
+		entry[channelID] = postID
+
+		return entry, nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to index post for %s: %w", obj.Tag, err)
+	}
+
+	return nil
+}
+
+func (p *Plugin) unindexPost(obj githubObject, channelID string) error {
+	err := p.client.KV.SetAtomicWithRetries(obj.key(), func(oldValue []byte) (any, error) {
+		entry, err := decodePostIndexEntry(oldValue)
+		if err != nil {
+			return nil, err
+		}
+
+		delete(entry, channelID)
+		if len(entry) == 0 {
+			return nil, nil
+		}
+
+		return entry, nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to remove post index entry for %s: %w", obj.Tag, err)
+	}
+
+	return nil
+}
+
+// hashtagPattern matches the hashtags appended to the bot's posts, e.g. #holochain.holochain.1234
+var hashtagPattern = regexp.MustCompile(`#[\w-]*\.[\w.-]+\.[\w.-]+`)
+
+// postHashtag returns the hashtag of the bot's post, which is always the last thing in the
+// message, or an empty string if the message has none.
+func postHashtag(message string) string {
+	tags := hashtagPattern.FindAllString(message, -1)
+	if len(tags) == 0 {
+		return ""
+	}
+
+	return tags[len(tags)-1]
+}
+
+// migratePostIndex seeds the post index from the bot's existing posts in the routed channels.
+// Posts created before the index existed can only be identified by their hashtag, so they are
+// stored by tag and moved to the object keyed index by adoptLegacyPost when next needed.
+//
+// The migration only runs once per installation, guarded by a cluster mutex so that only one
+// server in a cluster performs it. It runs in the background on activation, and events are only
+// handled once it has finished, as their posts would otherwise be duplicated. Channels that cannot
+// be migrated are logged and skipped, so that they do not keep the plugin from handling events,
+// and are migrated again on the next activation. Migrated channels are recorded, so that they
+// are not migrated twice.
+func (p *Plugin) migratePostIndex() {
+	defer close(p.postIndexMigrated)
+
+	mutex, err := cluster.NewMutex(p.API, postIndexMigrationKey)
+	if err != nil {
+		p.client.Log.Error("Failed to create post index migration mutex", "error", err.Error())
+		return
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	var done bool
+	if err = p.client.KV.Get(postIndexMigrationKey, &done); err != nil {
+		p.client.Log.Error("Failed to get post index migration state", "error", err.Error())
+		return
+	}
+	if done {
+		return
+	}
+
+	var targets []routeTarget
+	config := p.getConfiguration()
+	for _, r := range append(slices.Clip(config.routes), webhookEndpointRoutes(config.webhookEndpoints)...) {
+		target := routeTarget{Team: r.Team, Channel: r.Channel}
+		if !slices.Contains(targets, target) {
+			targets = append(targets, target)
+		}
+	}
+
+	failed := 0
+	for _, target := range targets {
+		if err = p.migrateChannel(target); err != nil {
+			p.client.Log.Warn("Skipping post index migration of channel", "team", target.Team, "channel", target.Channel, "error", err.Error())
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		p.client.Log.Warn("Post index migration is incomplete, it is continued on the next activation", "failed_channels", failed)
+		return
+	}
+
+	if _, err = p.client.KV.Set(postIndexMigrationKey, true); err != nil {
+		p.client.Log.Error("Failed to set post index migration state", "error", err.Error())
+	}
+}
+
+// migrateChannel seeds the post index from the bot's posts in the channel, unless that has
+// already been done.
+func (p *Plugin) migrateChannel(target routeTarget) error {
+	channel, err := p.getChannel(target.Team, target.Channel)
+	if err != nil {
+		return err
+	}
+
+	doneKey := postIndexMigrationKey + "_" + channel.Id
+
+	var done bool
+	if err = p.client.KV.Get(doneKey, &done); err != nil {
+		return fmt.Errorf("failed to get post index migration state of channel: %w", err)
+	}
+	if done {
+		return nil
+	}
+
+	count, err := p.migrateChannelPosts(channel.Id)
+	if err != nil {
+		return err
+	}
+
+	p.client.Log.Info("Seeded post index from existing posts", "team", target.Team, "channel", target.Channel, "count", count)
+
+	if _, err = p.client.KV.Set(doneKey, true); err != nil {
+		return fmt.Errorf("failed to set post index migration state of channel: %w", err)
+	}
+
+	return nil
+}
+
+// isPostIndexMigrated reports whether the post index migration has finished.
+func (p *Plugin) isPostIndexMigrated() bool {
+	select {
+	case <-p.postIndexMigrated:
+		return true
+	default:
+		return false
+	}
+}
+
+func (p *Plugin) migrateChannelPosts(channelID string) (int, error) {
+	count := 0
+	for page := 0; ; page++ {
+		postList, err := p.client.Post.GetPostsForChannel(channelID, page, 200)
+		if err != nil {
+			return count, fmt.Errorf("failed to get posts for channel: %w", err)
+		}
+		if len(postList.Order) == 0 {
+			return count, nil
+		}
+
+		for _, postID := range postList.Order {
+			post := postList.Posts[postID]
+			if post == nil || post.UserId != *p.botUserId || post.RootId != "" {
+				continue
+			}
+
+			tag := postHashtag(post.Message)
+			if tag == "" {
+				continue
+			}
+
+			err = p.client.KV.SetAtomicWithRetries(legacyTagKey(tag), func(oldValue []byte) (any, error) {
+				entry, err := decodePostIndexEntry(oldValue)
+				if err != nil {
+					return nil, err
+				}
+
+				// Posts are listed newest first, so keep the oldest post as the original
+				entry[channelID] = post.Id
+
+				return entry, nil
+			})
+			if err != nil {
+				return count, fmt.Errorf("failed to index post %s: %w", post.Id, err)
+			}
+			count++
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/google/go-github/v76/github"
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+func TestPostHashtag(t *testing.T) {
+	for name, tc := range map[string]struct {
+		message  string
+		expected string
+	}{
+		"pull request": {
+			message:  "Fix crash\nhttps://github.com/holochain/holochain/pull/12\n#holochain.holochain.12",
+			expected: "#holochain.holochain.12",
+		},
+		"release": {
+			message:  "holochain v0.4.0-rc.1\n#holochain.holochain.v0.4.0-rc.1",
+			expected: "#holochain.holochain.v0.4.0-rc.1",
+		},
+		"owner without name": {
+			message:  "Fix crash\n#.holochain.12",
+			expected: "#.holochain.12",
+		},
+		"last tag wins": {
+			message:  "Follow up to #holochain.lair.3\n#holochain.holochain.12",
+			expected: "#holochain.holochain.12",
+		},
+		"no tag": {
+			message: "Reminder about #12",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if tag := postHashtag(tc.message); tag != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, tag)
+			}
+		})
+	}
+}
+
+func TestLegacyTagKey(t *testing.T) {
+	key := legacyTagKey("#holochain.holochain.12")
+
+	if other := legacyTagKey("#Holochain.Holochain.12"); other != key {
+		t.Errorf("expected tags that differ in case to share key %s, got %s", key, other)
+	}
+	if other := legacyTagKey("#holochain.holochain.13"); other == key {
+		t.Errorf("expected different tags to have different keys, got %s for both", key)
+	}
+	if len(key) > 50 {
+		t.Errorf("expected key to fit the KV store's key length limit, got %d characters", len(key))
+	}
+}
+
+func TestLegacyPostAdoption(t *testing.T) {
+	// Webhook payloads usually carry the owner's login but no name, which the hashtags of posts
+	// created before the post index left empty
+	repo := &github.Repository{
+		ID:    github.Ptr(int64(1)),
+		Name:  github.Ptr("holochain"),
+		Owner: &github.User{Login: github.Ptr("holochain")},
+	}
+	namedRepo := &github.Repository{
+		ID:    github.Ptr(int64(1)),
+		Name:  github.Ptr("holochain"),
+		Owner: &github.User{Login: github.Ptr("holochain"), Name: github.Ptr("Holochain")},
+	}
+
+	for name, tc := range map[string]struct {
+		repo       *github.Repository
+		legacyPost string
+	}{
+		"owner without name": {repo: repo, legacyPost: "Fix crash\nhttps://github.com/holochain/holochain/pull/1\n#.holochain.1"},
+		"owner with name":    {repo: namedRepo, legacyPost: "Fix crash\nhttps://github.com/holochain/holochain/pull/1\n#Holochain.holochain.1"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			p, api := newTestPlugin()
+			api.addChannel("team", "prs", "channel")
+
+			legacy, appErr := api.CreatePost(&model.Post{UserId: *p.botUserId, ChannelId: "channel", Message: tc.legacyPost, IsPinned: true})
+			if appErr != nil {
+				t.Fatalf("unexpected error: %v", appErr)
+			}
+			if err := p.migrateChannel(routeTarget{Team: "team", Channel: "prs"}); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			pullRequest := &github.PullRequest{Number: github.Ptr(1), Title: github.Ptr("Fix crash"), State: github.Ptr("open")}
+			event := &github.PullRequestEvent{Action: github.Ptr("reopened"), Repo: tc.repo, PullRequest: pullRequest}
+			if err := p.ensurePullRequestPinned(event, "team", "prs"); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(api.posts) != 1 {
+				t.Fatalf("expected the legacy post to be adopted instead of posting again, got %d posts", len(api.posts))
+			}
+
+			post, err := p.getIndexedPost(pullRequestObject(tc.repo, pullRequest), "channel")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if post == nil || post.Id != legacy.Id {
+				t.Fatalf("expected the legacy post %s to be indexed, got %v", legacy.Id, post)
+			}
+		})
+	}
+}
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cbrgm/githubevents/v2/githubevents"
+	"github.com/google/go-github/v76/github"
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/pluginapi"
+)
+
+const (
+	// eventQueueSize is the number of events that can wait to be handled, split between the
+	// workers.
+	eventQueueSize    = 100
+	eventQueueWorkers = 4
+
+	// eventBacklogSize is the number of events that are held back while the post index is
+	// migrated, split between the workers, on top of those in the queue.
+	eventBacklogSize = 1000
+
+	// Failed events are retried after 1s, 2s, 4s and 8s before they are given up on.
+	eventMaxAttempts  = 5
+	eventRetryBackoff = time.Second
+
+	deadLetterKeyPrefix = "dead_letter_"
+	deadLetterTTL       = 30 * 24 * time.Hour
+)
+
+var errEventQueueFull = errors.New("event queue is full")
+
+// queuedEvent is a validated GitHub delivery waiting to be handled.
+type queuedEvent struct {
+	// handlers are the event handlers that have not handled the event yet.
+	handlers   []*githubevents.EventHandler
+	deliveryID string
+	eventName  string
+	// repository is the lower case full name of the event's repository, if it has one.
+	repository string
+	// endpoint is the webhook endpoint that the event was received on, or nil for /github.
+	endpoint *webhookEndpoint
+	payload  []byte
+	event    any
+	attempts int
+}
+
+// deadLetter is a delivery that could not be handled after all retries. The payload is kept so
+// that the delivery can be inspected and replayed.
+type deadLetter struct {
+	DeliveryID string          `json:"delivery_id"`
+	EventName  string          `json:"event_name"`
+	Payload    json.RawMessage `json:"payload"`
+	Error      string          `json:"error"`
+	Attempts   int             `json:"attempts"`
+	FailedAt   time.Time       `json:"failed_at"`
+}
+
+// eventQueue hands GitHub deliveries to a fixed number of workers, so that webhook requests can
+// be answered before GitHub's delivery timeout regardless of how long handling takes. Events of
+// the same repository always go to the same worker, so that they are handled in the order they
+// were received, e.g. a pull request is not pinned again by an "opened" event handled after its
+// "closed" event.
+type eventQueue struct {
+	plugin  *Plugin
+	workers []chan *queuedEvent
+	stop    chan struct{}
+	wg      sync.WaitGroup
+}
+
+func newEventQueue(p *Plugin) *eventQueue {
+	q := &eventQueue{
+		plugin: p,
+		stop:   make(chan struct{}),
+	}
+
+	for range eventQueueWorkers {
+		events := make(chan *queuedEvent, eventQueueSize/eventQueueWorkers)
+		q.workers = append(q.workers, events)
+
+		q.wg.Add(1)
+		go q.work(events)
+	}
+
+	return q
+}
+
+// worker returns the queue of the worker that handles the events of the repository.
+func (q *eventQueue) worker(repository string) chan *queuedEvent {
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(repository))
+
+	return q.workers[hash.Sum32()%uint32(len(q.workers))]
+}
+
+// enqueue adds the event to the queue of its repository's worker without blocking.
+func (q *eventQueue) enqueue(event *queuedEvent) error {
+	select {
+	case q.worker(event.repository) <- event:
+		return nil
+	default:
+		return errEventQueueFull
+	}
+}
+
+// close stops the workers once they finish their current event. Events that are still queued
+// are moved to the dead letters.
+func (q *eventQueue) close() {
+	close(q.stop)
+	q.wg.Wait()
+
+	for _, events := range q.workers {
+		for len(events) > 0 {
+			q.plugin.deadLetter(<-events, errors.New("plugin stopped before the event was handled"))
+		}
+	}
+}
+
+func (q *eventQueue) work(events chan *queuedEvent) {
+	defer q.wg.Done()
+
+	// Events are only handled once existing posts are in the post index. Until then they are moved
+	// to a backlog, so that deliveries are not refused while the migration runs.
+	var backlog []*queuedEvent
+	for migrated := false; !migrated; {
+		// Once the backlog is full the queue fills up and further deliveries are refused
+		receive := events
+		if len(backlog) >= eventBacklogSize/eventQueueWorkers {
+			receive = nil
+		}
+
+		select {
+		case <-q.stop:
+			for _, event := range backlog {
+				q.plugin.deadLetter(event, errors.New("plugin stopped before the event was handled"))
+			}
+			return
+		case event := <-receive:
+			backlog = append(backlog, event)
+		case <-q.plugin.postIndexMigrated:
+			migrated = true
+		}
+	}
+
+	for i, event := range backlog {
+		select {
+		case <-q.stop:
+			for _, event := range backlog[i:] {
+				q.plugin.deadLetter(event, errors.New("plugin stopped before the event was handled"))
+			}
+			return
+		default:
+			q.handle(event)
+		}
+	}
+
+	for {
+		select {
+		case <-q.stop:
+			return
+		case event := <-events:
+			q.handle(event)
+		}
+	}
+}
+
+// handle handles the event, retrying with exponential backoff until it succeeds or runs out of
+// attempts. Only the handlers that failed are retried, as the others may already have posted
+// about the event.
+func (q *eventQueue) handle(event *queuedEvent) {
+	backoff := eventRetryBackoff
+	for {
+		event.attempts++
+
+		ctx := withWebhookEndpoint(context.Background(), event.endpoint)
+
+		var failed []*githubevents.EventHandler
+		var errs []error
+		for _, handler := range event.handlers {
+			if err := handler.HandleEvent(ctx, event.deliveryID, event.eventName, event.event); err != nil {
+				failed = append(failed, handler)
+				errs = append(errs, err)
+			}
+		}
+		event.handlers = failed
+
+		err := errors.Join(errs...)
+		if err == nil {
+			return
+		}
+
+		if event.attempts >= eventMaxAttempts {
+			q.plugin.deadLetter(event, err)
+			return
+		}
+
+		q.plugin.client.Log.Warn("Failed to handle GitHub delivery, retrying",
+			"delivery_id", event.deliveryID, "event", event.eventName, "attempt", event.attempts, "error", err.Error())
+
+		select {
+		case <-q.stop:
+			q.plugin.deadLetter(event, fmt.Errorf("plugin stopped before retrying: %w", err))
+			return
+		case <-time.After(backoff):
+			backoff *= 2
+		}
+	}
+}
+
+// enqueueEvent parses a validated delivery and queues it for the workers, unless the same delivery
+// has already been received or no handler is interested in it. The returned response describes
+// what happened to the delivery.
+func (p *Plugin) enqueueEvent(handlers []*githubevents.EventHandler, endpoint *webhookEndpoint, deliveryID, eventName string, payload []byte) (*webhookResponse, error) {
+	response := &webhookResponse{
+		DeliveryID: deliveryID,
+		Event:      eventName,
+		Action:     payloadAction(payload),
+	}
+
+	event, err := github.ParseWebHook(eventName, payload)
+	if err != nil {
+		return nil, newWebhookError(http.StatusBadRequest, "could not parse webhook: %w", err)
+	}
+
+	if eventName == "ping" {
+		response.Status = webhookStatusPong
+		return response, nil
+	}
+
+	response.Handlers = eventHandlers(p.getConfiguration(), eventName, endpoint)
+	if len(response.Handlers) == 0 {
+		response.Status = webhookStatusIgnored
+		return response, nil
+	}
+
+	claimed, err := p.claimDelivery(deliveryID)
+	if err != nil {
+		return nil, err
+	}
+
+	p.client.Log.Info("Received GitHub delivery", "delivery_id", deliveryID, "event", eventName, "duplicate", !claimed)
+	if !claimed {
+		// Already received, acknowledge the redelivery without doing anything
+		response.Status = webhookStatusDuplicate
+		response.Handlers = nil
+		return response, nil
+	}
+
+	err = p.queue.enqueue(&queuedEvent{
+		handlers:   handlers,
+		deliveryID: deliveryID,
+		eventName:  eventName,
+		repository: eventRepository(event),
+		endpoint:   endpoint,
+		payload:    payload,
+		event:      event,
+	})
+	if err != nil {
+		// Allow GitHub to redeliver the event once there is room in the queue
+		if releaseErr := p.releaseDelivery(deliveryID); releaseErr != nil {
+			p.client.Log.Warn("Failed to release GitHub delivery", "delivery_id", deliveryID, "error", releaseErr.Error())
+		}
+
+		return nil, &webhookError{status: http.StatusServiceUnavailable, err: err}
+	}
+
+	response.Status = webhookStatusQueued
+	return response, nil
+}
+
+// eventRepository returns the lower case full name of the repository that the event belongs to,
+// or an empty string if it does not belong to one.
+func eventRepository(event any) string {
+	if event, ok := event.(interface{ GetRepo() *github.Repository }); ok {
+		return strings.ToLower(event.GetRepo().GetFullName())
+	}
+
+	return ""
+}
+
+// deadLetterKey is the key of the dead letter of a delivery. Deliveries without an ID get a
+// random one, so that they do not replace each other.
+func deadLetterKey(deliveryID string) string {
+	if deliveryID == "" {
+		deliveryID = model.NewId()
+	}
+
+	return deadLetterKeyPrefix + deliveryID
+}
+
+// deadLetter records an event that could not be handled. Dead letters expire after a while. The
+// delivery is released, so that it is handled again if it is redelivered from GitHub.
+func (p *Plugin) deadLetter(event *queuedEvent, cause error) {
+	p.client.Log.Error("Giving up on GitHub delivery",
+		"delivery_id", event.deliveryID, "event", event.eventName, "attempts", event.attempts, "error", cause.Error())
+
+	letter := deadLetter{
+		DeliveryID: event.deliveryID,
+		EventName:  event.eventName,
+		Payload:    event.payload,
+		Error:      cause.Error(),
+		Attempts:   event.attempts,
+		FailedAt:   time.Now(),
+	}
+
+	if _, err := p.client.KV.Set(deadLetterKey(event.deliveryID), letter, pluginapi.SetExpiry(deadLetterTTL)); err != nil {
+		p.client.Log.Error("Failed to store dead letter", "delivery_id", event.deliveryID, "error", err.Error())
+	}
+
+	if err := p.releaseDelivery(event.deliveryID); err != nil {
+		p.client.Log.Warn("Failed to release GitHub delivery", "delivery_id", event.deliveryID, "error", err.Error())
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cbrgm/githubevents/v2/githubevents"
+	"github.com/google/go-github/v76/github"
+)
+
+func TestEventRepository(t *testing.T) {
+	repo := &github.Repository{FullName: github.Ptr("Holochain/Holochain")}
+
+	for name, tc := range map[string]struct {
+		event    any
+		expected string
+	}{
+		"pull request":  {event: &github.PullRequestEvent{Repo: repo}, expected: "holochain/holochain"},
+		"workflow run":  {event: &github.WorkflowRunEvent{Repo: repo}, expected: "holochain/holochain"},
+		"no repository": {event: &github.PingEvent{}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if repository := eventRepository(tc.event); repository != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, repository)
+			}
+		})
+	}
+}
+
+func TestEventQueueWorker(t *testing.T) {
+	q := &eventQueue{}
+	for range eventQueueWorkers {
+		q.workers = append(q.workers, make(chan *queuedEvent, 1))
+	}
+
+	// Events of a repository are handled in order by always going to the same worker
+	worker := q.worker("holochain/holochain")
+	for range 10 {
+		if q.worker("holochain/holochain") != worker {
+			t.Fatal("expected events of the same repository to go to the same worker")
+		}
+	}
+
+	if err := q.enqueue(&queuedEvent{repository: "holochain/holochain"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := q.enqueue(&queuedEvent{repository: "holochain/holochain"}); err == nil {
+		t.Error("expected an error, got none")
+	}
+	if len(worker) != 1 {
+		t.Errorf("expected the event in the repository's worker queue, got %d events", len(worker))
+	}
+}
+
+func TestEventQueueBacklog(t *testing.T) {
+	p, _ := newTestPlugin()
+	p.postIndexMigrated = make(chan struct{})
+
+	var mu sync.Mutex
+	var handled []int
+	handler := githubevents.New("")
+	handler.OnIssuesEventAny(func(ctx context.Context, deliveryID string, eventName string, event *github.IssuesEvent) error {
+		mu.Lock()
+		defer mu.Unlock()
+		handled = append(handled, event.GetIssue().GetNumber())
+		return nil
+	})
+
+	q := newEventQueue(p)
+	defer q.close()
+
+	// While the post index is migrated, more events are accepted than fit in the queue
+	events := eventQueueSize/eventQueueWorkers + 10
+	for i := range events {
+		event := &queuedEvent{
+			handlers:   []*githubevents.EventHandler{handler},
+			eventName:  "issues",
+			repository: "holochain/holochain",
+			event:      &github.IssuesEvent{Action: github.Ptr("opened"), Issue: &github.Issue{Number: github.Ptr(i)}},
+		}
+
+		deadline := time.Now().Add(time.Second)
+		for q.enqueue(event) != nil {
+			if time.Now().After(deadline) {
+				t.Fatalf("expected event %d to be accepted during the migration", i)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	mu.Lock()
+	if len(handled) != 0 {
+		t.Errorf("expected no events to be handled during the migration, got %v", handled)
+	}
+	mu.Unlock()
+
+	close(p.postIndexMigrated)
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		mu.Lock()
+		done := len(handled) == events
+		mu.Unlock()
+		if done {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d events to be handled, got %d", events, len(handled))
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for i, number := range handled {
+		if number != i {
+			t.Fatalf("expected events to be handled in order, got %v", handled)
+		}
+	}
+}
+
+func TestDeadLetter(t *testing.T) {
+	p, api := newTestPlugin()
+
+	for _, deliveryID := range []string{"a", "b", "", ""} {
+		if _, err := p.claimDelivery(deliveryID); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		p.deadLetter(&queuedEvent{deliveryID: deliveryID, eventName: "issues", payload: []byte(`{}`)}, errors.New("failed"))
+	}
+
+	var letters int
+	for key := range api.kv {
+		if strings.HasPrefix(key, deadLetterKeyPrefix) {
+			letters++
+		}
+	}
+	if letters != 4 {
+		t.Errorf("expected a dead letter per delivery, got %d", letters)
+	}
+
+	var letter deadLetter
+	if err := p.client.KV.Get(deadLetterKey("a"), &letter); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if letter.DeliveryID != "a" || letter.Error != "failed" {
+		t.Errorf("unexpected dead letter %+v", letter)
+	}
+
+	// Dead letters can be redelivered from GitHub
+	if claimed, err := p.claimDelivery("a"); err != nil || !claimed {
+		t.Errorf("expected the delivery to be released, got %v, %v", claimed, err)
+	}
+}
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/cbrgm/githubevents/v2/githubevents"
+	"github.com/google/go-github/v76/github"
+	"github.com/mattermost/mattermost/server/public/pluginapi/cluster"
+)
+
+const (
+	reconcileJobKey             = "reconcile_pull_requests"
+	pinnedPullRequestsKeyPrefix = "pinned_"
+)
+
+// parseRepositoryList splits a list of "owner/repo" names separated by commas or whitespace.
+func parseRepositoryList(list string) ([]string, error) {
+	repositories := strings.FieldsFunc(list, func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	})
+
+	for _, repository := range repositories {
+		owner, name, found := strings.Cut(repository, "/")
+		if !found || owner == "" || name == "" || strings.Contains(name, "/") {
+			return nil, fmt.Errorf("invalid repository %q, expected owner/repo", repository)
+		}
+	}
+
+	return repositories, nil
+}
+
+// scheduleReconcileJob (re)starts the pull request reconciliation job with the configured
+// interval, or stops it if reconciliation is disabled.
+func (p *Plugin) scheduleReconcileJob() error {
+	p.jobLock.Lock()
+	defer p.jobLock.Unlock()
+
+	if p.reconcileJob != nil {
+		if err := p.reconcileJob.Close(); err != nil {
+			return fmt.Errorf("failed to stop reconcile job: %w", err)
+		}
+		p.reconcileJob = nil
+	}
+
+	config := p.getConfiguration()
+	if config.ReconcileIntervalMinutes <= 0 || len(config.reconcileRepositories) == 0 {
+		return nil
+	}
+
+	interval := time.Duration(config.ReconcileIntervalMinutes) * time.Minute
+	job, err := cluster.Schedule(p.API, reconcileJobKey, cluster.MakeWaitForInterval(interval), p.reconcilePullRequests)
+	if err != nil {
+		return fmt.Errorf("failed to schedule reconcile job: %w", err)
+	}
+	p.reconcileJob = job
+
+	return nil
+}
+
+// reconcilePullRequests makes the pinned posts in the pull request channels match the open,
+// non-draft pull requests of the configured repositories. Pull requests that were missed are
+// posted, and posts for pull requests that were closed or converted to draft are unpinned.
+func (p *Plugin) reconcilePullRequests() {
+	// Pull requests would be posted again if their existing posts are not in the post index yet
+	if !p.isPostIndexMigrated() {
+		p.client.Log.Info("Skipping pull request reconciliation until the post index migration has finished")
+		return
+	}
+
+	config := p.getConfiguration()
+	ctx := context.Background()
+
+	for _, repository := range config.reconcileRepositories {
+		if err := p.reconcileRepository(ctx, config, repository); err != nil {
+			p.client.Log.Error("Failed to reconcile pull requests", "repository", repository, "error", err.Error())
+		}
+	}
+}
+
+// pinnedPullRequest is a pull request that should be pinned in a channel.
+type pinnedPullRequest struct {
+	ChannelID string
+	Number    int64
+}
+
+// pinningPullRequestActions are the actions after which pull requests are pinned, so an open pull
+// request belongs in every channel that one of them is routed to.
+var pinningPullRequestActions = []string{
+	githubevents.PullRequestEventOpenedAction,
+	githubevents.PullRequestEventReadyForReviewAction,
+	githubevents.PullRequestEventReopenedAction,
+}
+
+// pinTargets returns the channels that the open pull request is pinned in.
+func pinTargets(routes []route, repo *github.Repository, pullRequest *github.PullRequest) []routeTarget {
+	var targets []routeTarget
+	for _, action := range pinningPullRequestActions {
+		for _, target := range matchRoutes(routes, newPullRequestRoutedEvent(action, repo, pullRequest)) {
+			if !slices.Contains(targets, target) {
+				targets = append(targets, target)
+			}
+		}
+	}
+
+	return targets
+}
+
+func (p *Plugin) reconcileRepository(ctx context.Context, config *Configuration, repository string) error {
+	client := p.githubClient()
+	owner, name, _ := strings.Cut(repository, "/")
+
+	repo, _, err := client.Repositories.Get(ctx, owner, name)
+	if err != nil {
+		return fmt.Errorf("failed to get repository: %w", err)
+	}
+
+	// Resolve every channel that pull requests of this repository can be routed to. Pins in
+	// these channels are owned by the plugin and can be removed. Channels that cannot be resolved
+	// are left alone until the next run.
+	routes := p.routes()
+	channelIDs := map[routeTarget]string{}
+	var errs []error
+	for _, r := range routes {
+		if !r.handles(eventPullRequest) {
+			continue
+		}
+
+		target := routeTarget{Team: r.Team, Channel: r.Channel}
+		if _, ok := channelIDs[target]; ok {
+			continue
+		}
+
+		channel, err := p.getChannel(target.Team, target.Channel)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		channelIDs[target] = channel.Id
+	}
+
+	wanted := map[pinnedPullRequest]bool{}
+
+	opts := &github.PullRequestListOptions{State: "open", ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		pullRequests, resp, err := client.PullRequests.List(ctx, owner, name, opts)
+		if err != nil {
+			return fmt.Errorf("failed to list pull requests: %w", err)
+		}
+
+		for _, pullRequest := range pullRequests {
+			if pullRequest.GetDraft() {
+				continue
+			}
+
+			for _, target := range pinTargets(routes, repo, pullRequest) {
+				channelID, ok := channelIDs[target]
+				if !ok {
+					continue
+				}
+				wanted[pinnedPullRequest{ChannelID: channelID, Number: int64(pullRequest.GetNumber())}] = true
+
+				if err = p.reconcileOpenPullRequest(config, repo, pullRequest, target, channelID); err != nil {
+					errs = append(errs, err)
+				}
+			}
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	if err = p.reconcilePinnedPosts(config, repo, channelIDs, wanted); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+func (p *Plugin) reconcileOpenPullRequest(config *Configuration, repo *github.Repository, pullRequest *github.PullRequest, target routeTarget, channelID string) error {
+	obj := pullRequestObject(repo, pullRequest)
+
+	post, err := p.getIndexedPost(obj, channelID)
+	if err != nil {
+		return err
+	}
+	if post != nil && post.IsPinned {
+		return nil
+	}
+
+	if config.ReconcileDryRun {
+		if post == nil {
+			p.client.Log.Info("Reconcile would post pull request", "pull_request", obj.Tag, "team", target.Team, "channel", target.Channel)
+		} else {
+			p.client.Log.Info("Reconcile would pin pull request", "pull_request", obj.Tag, "team", target.Team, "channel", target.Channel)
+		}
+		return nil
+	}
+
+	p.client.Log.Info("Reconcile is pinning pull request", "pull_request", obj.Tag, "team", target.Team, "channel", target.Channel)
+
+	// Templates for an event type also apply here, as there is no action
+	return p.ensurePullRequestPinned(&github.PullRequestEvent{Repo: repo, PullRequest: pullRequest}, target.Team, target.Channel)
+}
+
+// reconcilePinnedPosts unpins the repository's pinned pull request posts in the channels where
+// they are not wanted, and updates the repository's pinned pull request index to match.
+func (p *Plugin) reconcilePinnedPosts(config *Configuration, repo *github.Repository, channelIDs map[routeTarget]string, wanted map[pinnedPullRequest]bool) error {
+	numbers, err := p.getPinnedPullRequests(repo.GetID())
+	if err != nil {
+		return err
+	}
+
+	// Pull requests that were pinned before the index existed are added once they are seen open
+	var pinned, unpinned []int64
+	for key := range wanted {
+		if !slices.Contains(numbers, key.Number) && !slices.Contains(pinned, key.Number) {
+			pinned = append(pinned, key.Number)
+		}
+	}
+
+	var errs []error
+	for _, number := range numbers {
+		obj := pullRequestObject(repo, &github.PullRequest{Number: github.Ptr(int(number))})
+
+		// Keep the pull request in the index until it is unpinned everywhere
+		keep := false
+		for target, channelID := range channelIDs {
+			if wanted[pinnedPullRequest{ChannelID: channelID, Number: number}] {
+				keep = true
+				continue
+			}
+
+			post, err := p.getIndexedPost(obj, channelID)
+			if err != nil {
+				errs = append(errs, err)
+				keep = true
+				continue
+			}
+			if post == nil || !post.IsPinned {
+				continue
+			}
+
+			if config.ReconcileDryRun {
+				p.client.Log.Info("Reconcile would unpin pull request", "pull_request", obj.Tag, "team", target.Team, "channel", target.Channel)
+				keep = true
+				continue
+			}
+
+			p.client.Log.Info("Reconcile is unpinning pull request", "pull_request", obj.Tag, "team", target.Team, "channel", target.Channel)
+			if err = p.unpinMessage(obj, target.Team, target.Channel); err != nil {
+				errs = append(errs, err)
+				keep = true
+			}
+		}
+
+		if !keep {
+			unpinned = append(unpinned, number)
+		}
+	}
+
+	if err = p.updatePinnedPullRequests(repo.GetID(), pinned, unpinned); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// pinnedPullRequestsKey is the key of the numbers of the repository's pull requests that the bot
+// has pinned, which reconciliation checks for pins that are no longer wanted.
+func pinnedPullRequestsKey(repoID int64) string {
+	return pinnedPullRequestsKeyPrefix + strconv.FormatInt(repoID, 10)
+}
+
+func (p *Plugin) getPinnedPullRequests(repoID int64) ([]int64, error) {
+	var numbers []int64
+	if err := p.client.KV.Get(pinnedPullRequestsKey(repoID), &numbers); err != nil {
+		return nil, fmt.Errorf("failed to get pinned pull requests: %w", err)
+	}
+
+	return numbers, nil
+}
+
+// updatePinnedPullRequests adds and removes pull request numbers from the repository's pinned
+// pull request index.
+func (p *Plugin) updatePinnedPullRequests(repoID int64, pinned, unpinned []int64) error {
+	if len(pinned) == 0 && len(unpinned) == 0 {
+		return nil
+	}
+
+	err := p.client.KV.SetAtomicWithRetries(pinnedPullRequestsKey(repoID), func(oldValue []byte) (any, error) {
+		var numbers []int64
+		if len(oldValue) > 0 {
+			if err := json.Unmarshal(oldValue, &numbers); err != nil {
+				return nil, fmt.Errorf("failed to decode pinned pull requests: %w", err)
+			}
+		}
+
+		for _, number := range pinned {
+			if !slices.Contains(numbers, number) {
+				numbers = append(numbers, number)
+			}
+		}
+		numbers = slices.DeleteFunc(numbers, func(number int64) bool {
+			return slices.Contains(unpinned, number)
+		})
+		slices.Sort(numbers)
+
+		return numbers, nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update pinned pull requests: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/go-github/v76/github"
+)
+
+func TestPinTargets(t *testing.T) {
+	repo := &github.Repository{FullName: github.Ptr("holochain/holochain")}
+	pullRequest := &github.PullRequest{Number: github.Ptr(1)}
+
+	for name, tc := range map[string]struct {
+		routes   []route
+		expected []routeTarget
+	}{
+		"all actions": {
+			routes:   []route{{Events: []string{eventPullRequest}, Team: "core", Channel: "prs"}},
+			expected: []routeTarget{{"core", "prs"}},
+		},
+		"ready for review": {
+			routes:   []route{{Events: []string{eventPullRequest}, Actions: []string{"ready_for_review"}, Team: "core", Channel: "ready"}},
+			expected: []routeTarget{{"core", "ready"}},
+		},
+		"reopened": {
+			routes:   []route{{Events: []string{eventPullRequest}, Actions: []string{"reopened"}, Team: "core", Channel: "reopened"}},
+			expected: []routeTarget{{"core", "reopened"}},
+		},
+		"closed": {
+			routes: []route{{Events: []string{eventPullRequest}, Actions: []string{"closed"}, Team: "core", Channel: "merged"}},
+		},
+		"duplicate targets are removed": {
+			routes: []route{
+				{Events: []string{eventPullRequest}, Actions: []string{"opened"}, Team: "core", Channel: "prs"},
+				{Events: []string{eventPullRequest}, Actions: []string{"reopened"}, Team: "core", Channel: "prs"},
+			},
+			expected: []routeTarget{{"core", "prs"}},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if targets := pinTargets(tc.routes, repo, pullRequest); !reflect.DeepEqual(targets, tc.expected) {
+				t.Errorf("expected targets %v, got %v", tc.expected, targets)
+			}
+		})
+	}
+}
+
+func TestReconcilePinnedPosts(t *testing.T) {
+	channelIDs := map[routeTarget]string{{"core", "prs"}: "prs", {"core", "ready"}: "ready"}
+
+	for name, tc := range map[string]struct {
+		pinned         map[string]bool
+		wanted         []pinnedPullRequest
+		dryRun         bool
+		expectedPinned map[string]bool
+		expectedIndex  []int64
+	}{
+		"wanted everywhere": {
+			pinned:         map[string]bool{"prs": true, "ready": true},
+			wanted:         []pinnedPullRequest{{"prs", 1}, {"ready", 1}},
+			expectedPinned: map[string]bool{"prs": true, "ready": true},
+			expectedIndex:  []int64{1},
+		},
+		"wanted in one channel": {
+			pinned:         map[string]bool{"prs": true, "ready": true},
+			wanted:         []pinnedPullRequest{{"ready", 1}},
+			expectedPinned: map[string]bool{"prs": false, "ready": true},
+			expectedIndex:  []int64{1},
+		},
+		"not wanted": {
+			pinned:         map[string]bool{"prs": true, "ready": true},
+			expectedPinned: map[string]bool{"prs": false, "ready": false},
+			expectedIndex:  []int64{},
+		},
+		"dry run": {
+			pinned:         map[string]bool{"prs": true},
+			dryRun:         true,
+			expectedPinned: map[string]bool{"prs": true},
+			expectedIndex:  []int64{1},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			p, api := newTestPlugin()
+			obj := pullRequestObject(testRepo, &github.PullRequest{Number: github.Ptr(1)})
+
+			posts := map[string]string{}
+			for target, channelID := range channelIDs {
+				api.addChannel(target.Team, target.Channel, channelID)
+				if pinned, ok := tc.pinned[channelID]; ok {
+					posts[channelID] = createIndexedPost(t, p, obj, channelID, textContent(obj.Tag), pinned).Id
+				}
+			}
+			if err := p.updatePinnedPullRequests(testRepo.GetID(), []int64{1}, nil); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			wanted := map[pinnedPullRequest]bool{}
+			for _, key := range tc.wanted {
+				wanted[key] = true
+			}
+			config := &Configuration{ReconcileDryRun: tc.dryRun}
+			if err := p.reconcilePinnedPosts(config, testRepo, channelIDs, wanted); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			for channelID, expected := range tc.expectedPinned {
+				if pinned := api.post(posts[channelID]).IsPinned; pinned != expected {
+					t.Errorf("expected pinned %v in channel %s, got %v", expected, channelID, pinned)
+				}
+			}
+
+			index, err := p.getPinnedPullRequests(testRepo.GetID())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(index, tc.expectedIndex) {
+				t.Errorf("expected index %v, got %v", tc.expectedIndex, index)
+			}
+		})
+	}
+}
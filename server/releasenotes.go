@@ -0,0 +1,197 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// defaultReleaseNotesLength is the number of characters of release notes included in a release
+// post when no length is configured.
+const defaultReleaseNotesLength = 2000
+
+var (
+	htmlCommentPattern = regexp.MustCompile(`(?s)<!--.*?-->`)
+	summaryPattern     = regexp.MustCompile(`(?is)<summary>(.*?)</summary>`)
+	htmlBlockPattern   = regexp.MustCompile(`(?i)</?details[^>]*>|<br\s*/?>`)
+	headingPattern     = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	mentionPattern     = regexp.MustCompile(`(^|[^\w@/])@([A-Za-z0-9][A-Za-z0-9-]*(?:/[A-Za-z0-9._-]+)?)`)
+	issueRefPattern    = regexp.MustCompile(`(^|[^\w&/#\[])#(\d+)\b`)
+	breakingPattern    = regexp.MustCompile(`(?i)\bbreaking\b`)
+)
+
+// releaseNotesLength returns the configured length of release notes in release posts, which is
+// negative if they are left out.
+func (c *Configuration) releaseNotesLength() int {
+	if c.ReleaseNotesLength == 0 {
+		return defaultReleaseNotesLength
+	}
+
+	return c.ReleaseNotesLength
+}
+
+// releaseNotes converts the body of a release to markdown that is safe to post in Mattermost.
+// Breaking changes sections are moved to the top, and the notes are cut off after length
+// characters with a link to the full notes.
+func releaseNotes(repository, body, url string, length int) string {
+	if length < 0 {
+		return ""
+	}
+
+	lines := convertReleaseNotes(repository, body)
+	breaking, rest := splitBreakingChanges(lines)
+
+	var notes []string
+	if len(breaking) > 0 {
+		notes = append(notes, "**:warning: Breaking changes**")
+		notes = append(notes, breaking...)
+		if len(rest) > 0 {
+			notes = append(notes, "")
+		}
+	}
+	notes = append(notes, rest...)
+
+	text, truncated := truncateLines(notes, length)
+	if truncated {
+		text += fmt.Sprintf("\n\n[Read the full release notes](%s)", url)
+	}
+
+	return text
+}
+
+// convertReleaseNotes rewrites GitHub flavoured markdown for Mattermost. HTML that Mattermost
+// would show as is gets removed, @mentions are quoted so that they do not notify Mattermost users
+// with the same name, and issue references are linked to the repository.
+func convertReleaseNotes(repository, body string) []string {
+	body = strings.ReplaceAll(body, "\r\n", "\n")
+	body = htmlCommentPattern.ReplaceAllString(body, "")
+	body = summaryPattern.ReplaceAllString(body, "**$1**\n")
+	body = htmlBlockPattern.ReplaceAllString(body, "\n")
+
+	var lines []string
+	inCode := false
+	for line := range strings.Lines(strings.TrimSpace(body)) {
+		line = strings.TrimRight(line, " \t\n")
+
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inCode = !inCode
+			lines = append(lines, line)
+			continue
+		}
+		if inCode {
+			lines = append(lines, line)
+			continue
+		}
+		if line == "" && len(lines) > 0 && lines[len(lines)-1] == "" {
+			continue
+		}
+
+		// Code spans are every other part between backticks, and are kept as they are
+		parts := strings.Split(line, "`")
+		for i := 0; i < len(parts); i += 2 {
+			parts[i] = mentionPattern.ReplaceAllString(parts[i], "$1`@$2`")
+			parts[i] = issueRefPattern.ReplaceAllString(parts[i], fmt.Sprintf("$1[#$2](https://github.com/%s/issues/$2)", repository))
+		}
+		lines = append(lines, strings.Join(parts, "`"))
+	}
+
+	return lines
+}
+
+// headingLevel returns the level of a markdown heading, or 0 if the line is not a heading.
+func headingLevel(line string) int {
+	match := headingPattern.FindStringSubmatch(line)
+	if match == nil {
+		return 0
+	}
+
+	return len(match[1])
+}
+
+// splitBreakingChanges separates the sections with "breaking" in their heading from the rest of
+// the notes. A section ends at the next heading of the same or a higher level. Headings are
+// shown in bold in both parts.
+func splitBreakingChanges(lines []string) ([]string, []string) {
+	var breaking, rest []string
+
+	breakingLevel := 0
+	inCode := false
+	for _, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inCode = !inCode
+		}
+
+		level := 0
+		if !inCode {
+			level = headingLevel(line)
+		}
+
+		if level > 0 && breakingLevel > 0 && level <= breakingLevel {
+			breakingLevel = 0
+		}
+		if level > 0 && breakingLevel == 0 && breakingPattern.MatchString(line) {
+			// The section's heading is replaced by the highlight at the top
+			breakingLevel = level
+			continue
+		}
+
+		if level > 0 {
+			line = "**" + headingPattern.FindStringSubmatch(line)[2] + "**"
+		}
+
+		if breakingLevel > 0 {
+			breaking = append(breaking, line)
+		} else {
+			rest = append(rest, line)
+		}
+	}
+
+	return trimBlankLines(breaking), trimBlankLines(rest)
+}
+
+// trimBlankLines removes blank lines at the start and end.
+func trimBlankLines(lines []string) []string {
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	return lines
+}
+
+// truncateLines joins the lines, leaving out the lines that would make the text longer than
+// length characters. A code block that is cut off is closed, so that the rest of the post is not
+// shown as code.
+func truncateLines(lines []string, length int) (string, bool) {
+	var text strings.Builder
+	size := 0
+	inCode := false
+	for i, line := range lines {
+		lineSize := len([]rune(line))
+		if i == 0 && lineSize > length {
+			return truncateText(length, line), true
+		}
+		if i > 0 && size+1+lineSize > length {
+			result := strings.TrimRight(text.String(), "\n")
+			if inCode {
+				result += "\n```"
+			}
+			return result, true
+		}
+
+		if i > 0 {
+			text.WriteString("\n")
+			size++
+		}
+		text.WriteString(line)
+		size += lineSize
+
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inCode = !inCode
+		}
+	}
+
+	return text.String(), false
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestReleaseNotes(t *testing.T) {
+	url := "https://github.com/holochain/holochain/releases/tag/v0.4.0"
+
+	for name, tc := range map[string]struct {
+		body     string
+		length   int
+		expected string
+	}{
+		"empty": {
+			length: 100,
+		},
+		"disabled": {
+			body:   "## Changes\n- Fix crash",
+			length: -1,
+		},
+		"mentions and issue references": {
+			body:     "## Changes\r\n- Fix crash (#12) by @alice, thanks @here\r\n- Keep `@bob` and [#3](https://example.com) and me@example.com",
+			length:   1000,
+			expected: "**Changes**\n- Fix crash ([#12](https://github.com/holochain/holochain/issues/12)) by `@alice`, thanks `@here`\n- Keep `@bob` and [#3](https://example.com) and me@example.com",
+		},
+		"html": {
+			body:     "<!-- generated -->\n<details><summary>Commits</summary>\n\n- abc</details>",
+			length:   1000,
+			expected: "**Commits**\n\n- abc",
+		},
+		"breaking changes first": {
+			body:   "## Features\n- New API\n\n## ⚠ Breaking changes\n- Removed `old`\n### Migration\nUse `new`\n\n## Fixes\n- Fix crash",
+			length: 1000,
+			expected: "**:warning: Breaking changes**\n- Removed `old`\n**Migration**\nUse `new`\n\n" +
+				"**Features**\n- New API\n\n**Fixes**\n- Fix crash",
+		},
+		"truncated": {
+			body:     "## Changes\n- One\n- Two\n- Three",
+			length:   20,
+			expected: "**Changes**\n- One\n\n[Read the full release notes](" + url + ")",
+		},
+		"truncated in code block": {
+			body:     "```\nline one\nline two\n```",
+			length:   15,
+			expected: "```\nline one\n```\n\n[Read the full release notes](" + url + ")",
+		},
+		"code blocks kept as they are": {
+			body:     "```\n# not a heading @alice #1\n```",
+			length:   1000,
+			expected: "```\n# not a heading @alice #1\n```",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if notes := releaseNotes("holochain/holochain", tc.body, url, tc.length); notes != tc.expected {
+				t.Errorf("expected notes\n%s\ngot\n%s", tc.expected, notes)
+			}
+		})
+	}
+}
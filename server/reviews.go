@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/cbrgm/githubevents/v2/githubevents"
+	"github.com/google/go-github/v76/github"
+)
+
+// registerReviewHandlers posts submitted reviews, together with their inline comments, as replies
+// to the pull request's post, and notifies reviewers in Mattermost when their review is requested.
+func (p *Plugin) registerReviewHandlers(eventHandler *githubevents.EventHandler) {
+	eventHandler.OnPullRequestReviewEventSubmitted(
+		func(ctx context.Context, deliveryID string, eventName string, event *github.PullRequestReviewEvent) error {
+			obj := pullRequestObject(event.GetRepo(), event.GetPullRequest())
+
+			// Inline comments are sent as separate events before the review is submitted, so they
+			// are fetched here to post the whole review as a single reply
+			comments, err := p.reviewComments(ctx, event)
+			if err != nil {
+				p.client.Log.Warn("Failed to list review comments", "pull_request", obj.Tag, "error", err.Error())
+			}
+
+			return p.replyToPosts(ctx, deliveryID, obj, p.reviewMessage(event.GetReview(), comments))
+		})
+
+	eventHandler.OnPullRequestReviewEventDismissed(
+		func(ctx context.Context, deliveryID string, eventName string, event *github.PullRequestReviewEvent) error {
+			obj := pullRequestObject(event.GetRepo(), event.GetPullRequest())
+			review := event.GetReview()
+
+			return p.replyToPosts(ctx, deliveryID, obj, fmt.Sprintf(":heavy_minus_sign: %s dismissed the [review](%s) by %s",
+				event.GetSender().GetLogin(), review.GetHTMLURL(), p.mention(review.GetUser().GetLogin())))
+		})
+
+	eventHandler.OnPullRequestEventReviewRequested(
+		func(ctx context.Context, deliveryID string, eventName string, event *github.PullRequestEvent) error {
+			// Requests for a team review carry no reviewer, and team members get their own event
+			reviewer := event.GetRequestedReviewer()
+			if reviewer == nil {
+				return nil
+			}
+
+			pullRequest := event.GetPullRequest()
+
+			return p.sendDirectMessage(reviewer.GetLogin(), fmt.Sprintf(":eyes: %s requested your review on [%s#%d %s](%s)",
+				event.GetSender().GetLogin(),
+				event.GetRepo().GetFullName(), pullRequest.GetNumber(), pullRequest.GetTitle(),
+				pullRequest.GetHTMLURL()))
+		})
+}
+
+// maxReviewComments is how many inline comments are included in the reply for a review. Longer
+// reviews link to the review for the other comments.
+const maxReviewComments = 10
+
+func (p *Plugin) reviewComments(ctx context.Context, event *github.PullRequestReviewEvent) ([]*github.PullRequestComment, error) {
+	repo := event.GetRepo()
+
+	comments, _, err := p.githubClient().PullRequests.ListReviewComments(ctx,
+		repo.GetOwner().GetLogin(), repo.GetName(), event.GetPullRequest().GetNumber(), event.GetReview().GetID(),
+		&github.ListOptions{PerPage: maxReviewComments + 1})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list comments of review %d: %w", event.GetReview().GetID(), err)
+	}
+
+	return comments, nil
+}
+
+func (p *Plugin) reviewMessage(review *github.PullRequestReview, comments []*github.PullRequestComment) string {
+	reviewer := p.mention(review.GetUser().GetLogin())
+
+	var summary string
+	switch strings.ToLower(review.GetState()) {
+	case "approved":
+		summary = fmt.Sprintf(":white_check_mark: %s [approved](%s)", reviewer, review.GetHTMLURL())
+	case "changes_requested":
+		summary = fmt.Sprintf(":x: %s [requested changes](%s)", reviewer, review.GetHTMLURL())
+	default:
+		summary = fmt.Sprintf(":speech_balloon: %s [reviewed](%s)", reviewer, review.GetHTMLURL())
+	}
+
+	message := []string{summary}
+	if body := strings.TrimSpace(review.GetBody()); body != "" {
+		message = append(message, quote(body))
+	}
+
+	for i, comment := range comments {
+		if i == maxReviewComments {
+			message = append(message, fmt.Sprintf("[More comments](%s)", review.GetHTMLURL()))
+			break
+		}
+
+		message = append(message, reviewCommentMessage(comment))
+	}
+
+	return strings.Join(message, "\n")
+}
+
+func reviewCommentMessage(comment *github.PullRequestComment) string {
+	summary := fmt.Sprintf("[`%s`](%s)", comment.GetPath(), comment.GetHTMLURL())
+
+	if body := strings.TrimSpace(comment.GetBody()); body != "" {
+		return fmt.Sprintf("%s\n%s", summary, quote(body))
+	}
+
+	return summary
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/v76/github"
+)
+
+func TestReviewMessage(t *testing.T) {
+	review := func(state, body string) *github.PullRequestReview {
+		return &github.PullRequestReview{
+			State:   github.Ptr(state),
+			Body:    github.Ptr(body),
+			HTMLURL: github.Ptr("https://github.com/holochain/holochain/pull/1#pullrequestreview-1"),
+			User:    &github.User{Login: github.Ptr("octocat")},
+		}
+	}
+	comment := func(i int) *github.PullRequestComment {
+		return &github.PullRequestComment{
+			Path:    github.Ptr(fmt.Sprintf("src/%d.rs", i)),
+			Body:    github.Ptr("Typo"),
+			HTMLURL: github.Ptr(fmt.Sprintf("https://github.com/holochain/holochain/pull/1#discussion_r%d", i)),
+		}
+	}
+	url := "(https://github.com/holochain/holochain/pull/1#pullrequestreview-1)"
+
+	var manyComments []*github.PullRequestComment
+	for i := range maxReviewComments + 1 {
+		manyComments = append(manyComments, comment(i))
+	}
+	var expectedManyComments []string
+	for i := range maxReviewComments {
+		expectedManyComments = append(expectedManyComments,
+			fmt.Sprintf("[`src/%d.rs`](https://github.com/holochain/holochain/pull/1#discussion_r%d)\n> Typo", i, i))
+	}
+
+	for name, tc := range map[string]struct {
+		review   *github.PullRequestReview
+		comments []*github.PullRequestComment
+		expected string
+	}{
+		"approved": {
+			review:   review("APPROVED", ""),
+			expected: ":white_check_mark: octocat [approved]" + url,
+		},
+		"changes requested": {
+			review:   review("changes_requested", "Please add a test"),
+			expected: ":x: octocat [requested changes]" + url + "\n> Please add a test",
+		},
+		"commented with inline comments": {
+			review:   review("commented", ""),
+			comments: []*github.PullRequestComment{comment(1), comment(2)},
+			expected: ":speech_balloon: octocat [reviewed]" + url +
+				"\n[`src/1.rs`](https://github.com/holochain/holochain/pull/1#discussion_r1)\n> Typo" +
+				"\n[`src/2.rs`](https://github.com/holochain/holochain/pull/1#discussion_r2)\n> Typo",
+		},
+		"too many inline comments": {
+			review:   review("commented", "Some nits"),
+			comments: manyComments,
+			expected: ":speech_balloon: octocat [reviewed]" + url + "\n> Some nits\n" +
+				strings.Join(expectedManyComments, "\n") + "\n[More comments]" + url,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			p, _ := newTestPlugin()
+			if message := p.reviewMessage(tc.review, tc.comments); message != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, message)
+			}
+		})
+	}
+}
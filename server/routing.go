@@ -0,0 +1,295 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/google/go-github/v76/github"
+)
+
+const (
+	eventIssues      = "issues"
+	eventPullRequest = "pull_request"
+	eventRelease     = "release"
+)
+
+// supportedEvents are the GitHub event types that routes can match on.
+var supportedEvents = []string{eventIssues, eventPullRequest, eventRelease}
+
+// route sends the GitHub events it matches to a Mattermost channel. Every condition that is set
+// must match for the route to apply, and a condition with several values matches if any of the
+// values match. Conditions that are not set match everything.
+type route struct {
+	// Repositories are globs matched against the repository's full name, e.g. "holochain/*".
+	Repositories []string `json:"repositories"`
+	// Events are GitHub event types, e.g. "pull_request".
+	Events []string `json:"events"`
+	// Actions are event actions, e.g. "opened".
+	Actions []string `json:"actions"`
+	// Labels match issues and pull requests that have at least one of the labels.
+	Labels []string `json:"labels"`
+	// BaseBranches match pull requests targeting one of the branches.
+	BaseBranches []string `json:"base_branches"`
+	// Authors are the GitHub logins of issue, pull request or release authors.
+	Authors []string `json:"authors"`
+
+	// StaleDays is how many days a pull request can go without activity before a reminder is
+	// posted, overriding the configured default. Negative values disable reminders.
+	StaleDays int `json:"stale_days,omitempty"`
+
+	// Team is the name of the Mattermost team, which can be left out if Channel is given in
+	// "team/channel" notation or a default team is configured.
+	Team    string `json:"team"`
+	Channel string `json:"channel"`
+}
+
+// routeTarget is a channel that an event is sent to.
+type routeTarget struct {
+	Team    string
+	Channel string
+}
+
+// routedEvent holds the properties of a GitHub event that routes match on.
+type routedEvent struct {
+	Event      string
+	Action     string
+	Repository string
+	Labels     []string
+	BaseBranch string
+	Author     string
+}
+
+func issuesRoutedEvent(event *github.IssuesEvent) routedEvent {
+	issue := event.GetIssue()
+
+	return routedEvent{
+		Event:      eventIssues,
+		Action:     event.GetAction(),
+		Repository: event.GetRepo().GetFullName(),
+		Labels:     labelNames(issue.Labels),
+		Author:     issue.GetUser().GetLogin(),
+	}
+}
+
+func pullRequestRoutedEvent(event *github.PullRequestEvent) routedEvent {
+	return newPullRequestRoutedEvent(event.GetAction(), event.GetRepo(), event.GetPullRequest())
+}
+
+func newPullRequestRoutedEvent(action string, repo *github.Repository, pullRequest *github.PullRequest) routedEvent {
+	return routedEvent{
+		Event:      eventPullRequest,
+		Action:     action,
+		Repository: repo.GetFullName(),
+		Labels:     labelNames(pullRequest.Labels),
+		BaseBranch: pullRequest.GetBase().GetRef(),
+		Author:     pullRequest.GetUser().GetLogin(),
+	}
+}
+
+func releaseRoutedEvent(event *github.ReleaseEvent) routedEvent {
+	return routedEvent{
+		Event:      eventRelease,
+		Action:     event.GetAction(),
+		Repository: event.GetRepo().GetFullName(),
+		Author:     event.GetRelease().GetAuthor().GetLogin(),
+	}
+}
+
+func labelNames(labels []*github.Label) []string {
+	names := make([]string, 0, len(labels))
+	for _, label := range labels {
+		names = append(names, label.GetName())
+	}
+
+	return names
+}
+
+// parseRoutes reads the routing rules from the configuration. The channels configured for each
+// event type are kept as routes of their own, so that existing installations keep working.
+func parseRoutes(config *Configuration) ([]route, error) {
+	teamName := strings.TrimSpace(config.MattermostTeamName)
+
+	var routes []route
+	if rules := strings.TrimSpace(config.RoutingRules); rules != "" {
+		if err := json.Unmarshal([]byte(rules), &routes); err != nil {
+			return nil, fmt.Errorf("failed to parse routing rules: %w", err)
+		}
+	}
+
+	for i := range routes {
+		if err := routes[i].resolve(teamName); err != nil {
+			return nil, fmt.Errorf("invalid routing rule %d: %w", i+1, err)
+		}
+	}
+
+	for _, legacy := range []struct {
+		event   string
+		channel string
+	}{
+		{eventIssues, config.MattermostIssueFeedChannelName},
+		{eventPullRequest, config.MattermostPullRequestChannelName},
+		{eventRelease, config.MattermostReleaseCreatedChannelName},
+	} {
+		team, channel := splitChannelName(legacy.channel)
+		if team == "" {
+			team = teamName
+		}
+		if team == "" || channel == "" {
+			continue
+		}
+
+		routes = append(routes, route{
+			Events:  []string{legacy.event},
+			Team:    team,
+			Channel: channel,
+		})
+	}
+
+	return routes, nil
+}
+
+// splitChannelName splits a channel given in "team/channel" notation. The team is empty if the
+// channel name does not include one.
+func splitChannelName(name string) (string, string) {
+	team, channel, found := strings.Cut(strings.TrimSpace(name), "/")
+	if !found {
+		return "", team
+	}
+
+	return strings.TrimSpace(team), strings.TrimSpace(channel)
+}
+
+// resolve splits a channel given in "team/channel" notation, falls back to the default team if no
+// team is given, and validates the route.
+func (r *route) resolve(defaultTeam string) error {
+	team, channel := splitChannelName(r.Channel)
+	r.Team = strings.TrimSpace(r.Team)
+	if team != "" {
+		if r.Team != "" && r.Team != team {
+			return fmt.Errorf("team %q does not match channel %q", r.Team, r.Channel)
+		}
+		r.Team = team
+	}
+	if r.Team == "" {
+		r.Team = defaultTeam
+	}
+	r.Channel = channel
+
+	return r.validate()
+}
+
+func (r *route) validate() error {
+	if r.Team == "" {
+		return errors.New("team is not set and there is no default team")
+	}
+	if r.Channel == "" {
+		return errors.New("channel is not set")
+	}
+
+	return r.validateConditions()
+}
+
+// validateConditions checks the conditions of the route, regardless of its channel.
+func (r *route) validateConditions() error {
+	for _, event := range r.Events {
+		if !slices.Contains(supportedEvents, event) {
+			return fmt.Errorf("unsupported event %q, expected one of %s", event, strings.Join(supportedEvents, ", "))
+		}
+	}
+
+	for _, pattern := range r.Repositories {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid repository pattern %q: %w", pattern, err)
+		}
+	}
+
+	return nil
+}
+
+// handles reports whether the route can match events of the given type.
+func (r *route) handles(event string) bool {
+	return len(r.Events) == 0 || slices.Contains(r.Events, event)
+}
+
+func (r *route) matches(event routedEvent) bool {
+	if !r.handles(event.Event) {
+		return false
+	}
+
+	if len(r.Actions) > 0 && !slices.Contains(r.Actions, event.Action) {
+		return false
+	}
+
+	if len(r.Repositories) > 0 && !slices.ContainsFunc(r.Repositories, func(pattern string) bool {
+		matched, _ := path.Match(strings.ToLower(pattern), strings.ToLower(event.Repository))
+		return matched
+	}) {
+		return false
+	}
+
+	if len(r.Labels) > 0 && !slices.ContainsFunc(r.Labels, func(label string) bool {
+		return slices.ContainsFunc(event.Labels, func(eventLabel string) bool {
+			return strings.EqualFold(label, eventLabel)
+		})
+	}) {
+		return false
+	}
+
+	if len(r.BaseBranches) > 0 && !slices.Contains(r.BaseBranches, event.BaseBranch) {
+		return false
+	}
+
+	if len(r.Authors) > 0 && !slices.ContainsFunc(r.Authors, func(author string) bool {
+		return strings.EqualFold(author, event.Author)
+	}) {
+		return false
+	}
+
+	return true
+}
+
+// routeTeams returns the names of all teams that the routes send events to.
+func routeTeams(routes []route) []string {
+	var teams []string
+	for _, r := range routes {
+		if !slices.Contains(teams, r.Team) {
+			teams = append(teams, r.Team)
+		}
+	}
+
+	return teams
+}
+
+// matchRoutes returns the channels that the event should be sent to, without duplicates.
+func matchRoutes(routes []route, event routedEvent) []routeTarget {
+	var targets []routeTarget
+	for _, r := range routes {
+		if !r.matches(event) {
+			continue
+		}
+
+		target := routeTarget{Team: r.Team, Channel: r.Channel}
+		if !slices.Contains(targets, target) {
+			targets = append(targets, target)
+		}
+	}
+
+	return targets
+}
+
+// forEachTarget calls handle for every channel the event is routed to. All targets are handled
+// even if some of them fail, and the errors are returned together.
+func forEachTarget(routes []route, event routedEvent, handle func(target routeTarget) error) error {
+	var errs []error
+	for _, target := range matchRoutes(routes, event) {
+		if err := handle(target); err != nil {
+			errs = append(errs, fmt.Errorf("%s/%s: %w", target.Team, target.Channel, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
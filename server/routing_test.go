@@ -0,0 +1,167 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMatchRoutes(t *testing.T) {
+	routes := []route{
+		{Events: []string{eventPullRequest}, Team: "core", Channel: "prs"},
+		{Repositories: []string{"holochain/lair*"}, Team: "tooling", Channel: "lair"},
+		{Events: []string{eventIssues}, Labels: []string{"Bug"}, Team: "core", Channel: "bugs"},
+		{Events: []string{eventPullRequest}, BaseBranches: []string{"main"}, Actions: []string{"closed"}, Team: "core", Channel: "merged"},
+		{Authors: []string{"dependabot[bot]"}, Team: "core", Channel: "prs"},
+	}
+
+	for name, tc := range map[string]struct {
+		event           routedEvent
+		expectedTargets []routeTarget
+	}{
+		"no matching route": {
+			event:           routedEvent{Event: eventRelease, Repository: "holochain/holochain"},
+			expectedTargets: nil,
+		},
+		"event type": {
+			event:           routedEvent{Event: eventPullRequest, Action: "opened", Repository: "holochain/holochain"},
+			expectedTargets: []routeTarget{{"core", "prs"}},
+		},
+		"repository glob is case insensitive": {
+			event:           routedEvent{Event: eventRelease, Repository: "Holochain/Lair-Keystore"},
+			expectedTargets: []routeTarget{{"tooling", "lair"}},
+		},
+		"repository glob does not match across owners": {
+			event:           routedEvent{Event: eventRelease, Repository: "other/lair"},
+			expectedTargets: nil,
+		},
+		"label": {
+			event:           routedEvent{Event: eventIssues, Repository: "holochain/holochain", Labels: []string{"enhancement", "bug"}},
+			expectedTargets: []routeTarget{{"core", "bugs"}},
+		},
+		"missing label": {
+			event:           routedEvent{Event: eventIssues, Repository: "holochain/holochain", Labels: []string{"enhancement"}},
+			expectedTargets: nil,
+		},
+		"base branch and action": {
+			event:           routedEvent{Event: eventPullRequest, Action: "closed", Repository: "holochain/holochain", BaseBranch: "main"},
+			expectedTargets: []routeTarget{{"core", "prs"}, {"core", "merged"}},
+		},
+		"base branch with other action": {
+			event:           routedEvent{Event: eventPullRequest, Action: "opened", Repository: "holochain/holochain", BaseBranch: "main"},
+			expectedTargets: []routeTarget{{"core", "prs"}},
+		},
+		"duplicate targets are removed": {
+			event:           routedEvent{Event: eventPullRequest, Action: "opened", Repository: "holochain/holochain", Author: "Dependabot[bot]"},
+			expectedTargets: []routeTarget{{"core", "prs"}},
+		},
+		"several routes": {
+			event:           routedEvent{Event: eventIssues, Repository: "holochain/lair", Labels: []string{"bug"}},
+			expectedTargets: []routeTarget{{"tooling", "lair"}, {"core", "bugs"}},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			targets := matchRoutes(routes, tc.event)
+			if !reflect.DeepEqual(targets, tc.expectedTargets) {
+				t.Errorf("expected targets %v, got %v", tc.expectedTargets, targets)
+			}
+		})
+	}
+}
+
+func TestParseRoutes(t *testing.T) {
+	for name, tc := range map[string]struct {
+		config         Configuration
+		expectedRoutes []route
+		expectedErr    bool
+	}{
+		"nothing configured": {
+			config:         Configuration{},
+			expectedRoutes: nil,
+		},
+		"legacy channels": {
+			config: Configuration{
+				MattermostTeamName:               "core",
+				MattermostPullRequestChannelName: " prs ",
+			},
+			expectedRoutes: []route{
+				{Events: []string{eventPullRequest}, Team: "core", Channel: "prs"},
+			},
+		},
+		"legacy channels without team": {
+			config: Configuration{
+				MattermostPullRequestChannelName: "prs",
+			},
+			expectedRoutes: nil,
+		},
+		"rules default to the configured team": {
+			config: Configuration{
+				MattermostTeamName: "core",
+				RoutingRules:       `[{"repositories": ["holochain/*"], "channel": "feed"}]`,
+			},
+			expectedRoutes: []route{
+				{Repositories: []string{"holochain/*"}, Team: "core", Channel: "feed"},
+			},
+		},
+		"team/channel notation": {
+			config: Configuration{
+				MattermostTeamName:             "core",
+				MattermostIssueFeedChannelName: "community/issues",
+				RoutingRules:                   `[{"channel": "tooling/lair"}, {"team": "tooling", "channel": "tooling/hc-spin"}]`,
+			},
+			expectedRoutes: []route{
+				{Team: "tooling", Channel: "lair"},
+				{Team: "tooling", Channel: "hc-spin"},
+				{Events: []string{eventIssues}, Team: "community", Channel: "issues"},
+			},
+		},
+		"stale days": {
+			config: Configuration{
+				MattermostTeamName: "core",
+				RoutingRules:       `[{"events": ["pull_request"], "channel": "prs", "stale_days": 3}]`,
+			},
+			expectedRoutes: []route{
+				{Events: []string{eventPullRequest}, StaleDays: 3, Team: "core", Channel: "prs"},
+			},
+		},
+		"conflicting team": {
+			config:      Configuration{RoutingRules: `[{"team": "core", "channel": "tooling/lair"}]`},
+			expectedErr: true,
+		},
+		"invalid JSON": {
+			config:      Configuration{RoutingRules: `{`},
+			expectedErr: true,
+		},
+		"missing team": {
+			config:      Configuration{RoutingRules: `[{"channel": "feed"}]`},
+			expectedErr: true,
+		},
+		"missing channel": {
+			config:      Configuration{RoutingRules: `[{"team": "core"}]`},
+			expectedErr: true,
+		},
+		"unsupported event": {
+			config:      Configuration{RoutingRules: `[{"team": "core", "channel": "feed", "events": ["star"]}]`},
+			expectedErr: true,
+		},
+		"invalid repository pattern": {
+			config:      Configuration{RoutingRules: `[{"team": "core", "channel": "feed", "repositories": ["holochain/["]}]`},
+			expectedErr: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			routes, err := parseRoutes(&tc.config)
+			if tc.expectedErr {
+				if err == nil {
+					t.Errorf("expected an error, got routes %v", routes)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(routes, tc.expectedRoutes) {
+				t.Errorf("expected routes %v, got %v", tc.expectedRoutes, routes)
+			}
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+const (
+	// githubHookRangesInterval is how often the hook ranges published by GitHub are refreshed,
+	// and githubHookRangesRetry how soon a failed refresh is tried again.
+	githubHookRangesInterval = 24 * time.Hour
+	githubHookRangesRetry    = 5 * time.Minute
+
+	// githubHookRangesTimeout is short enough for the first fetch, which a webhook request waits
+	// for, to finish within GitHub's 10 second delivery timeout.
+	githubHookRangesTimeout = 5 * time.Second
+)
+
+// githubHookRanges caches the source ranges of webhook deliveries published by GitHub's meta API.
+type githubHookRanges struct {
+	lock        sync.Mutex
+	prefixes    []netip.Prefix
+	nextRefresh time.Time
+	refreshing  bool
+}
+
+// parseSourceRanges reads a list of CIDR ranges separated by commas or white space. Single
+// addresses are taken as ranges of their own.
+func parseSourceRanges(list string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, value := range strings.FieldsFunc(list, func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	}) {
+		prefix, err := parseSourceRange(value)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, prefix)
+	}
+
+	return prefixes, nil
+}
+
+func parseSourceRange(value string) (netip.Prefix, error) {
+	if strings.Contains(value, "/") {
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("invalid CIDR range %q: %w", value, err)
+		}
+
+		return prefix.Masked(), nil
+	}
+
+	addr, err := netip.ParseAddr(value)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid address %q: %w", value, err)
+	}
+	addr = addr.Unmap()
+
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// containsAddr reports whether any of the ranges contains the address.
+func containsAddr(prefixes []netip.Prefix, addr netip.Addr) bool {
+	return slices.ContainsFunc(prefixes, func(prefix netip.Prefix) bool {
+		return prefix.Contains(addr)
+	})
+}
+
+// checkWebhookSourceSettings returns an error if requests would be rejected regardless of where
+// they come from.
+func checkWebhookSourceSettings(config *Configuration) error {
+	if !config.RestrictWebhookSources || len(config.webhookSourceRanges) > 0 {
+		return nil
+	}
+	if config.RefreshWebhookSourceRanges && config.hasGitHubCredentials() {
+		return nil
+	}
+
+	return errors.New("webhook sources are restricted, but no source ranges are configured or fetched from GitHub")
+}
+
+// requestSourceAddress returns the address that the request originates from. X-Forwarded-For is
+// only followed for as long as the addresses in it were added by trusted proxies, as anyone can
+// send the header.
+func requestSourceAddress(r *http.Request, trustedProxies []netip.Prefix) (netip.Addr, error) {
+	var addr netip.Addr
+	if addrPort, err := netip.ParseAddrPort(r.RemoteAddr); err == nil {
+		addr = addrPort.Addr()
+	} else if addr, err = netip.ParseAddr(r.RemoteAddr); err != nil {
+		return netip.Addr{}, fmt.Errorf("invalid remote address %q: %w", r.RemoteAddr, err)
+	}
+	addr = addr.Unmap()
+
+	forwarded := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(forwarded) - 1; i >= 0 && containsAddr(trustedProxies, addr); i-- {
+		value := strings.TrimSpace(forwarded[i])
+		if value == "" {
+			continue
+		}
+
+		next, err := netip.ParseAddr(value)
+		if err != nil {
+			return netip.Addr{}, fmt.Errorf("invalid X-Forwarded-For address %q: %w", value, err)
+		}
+		addr = next.Unmap()
+	}
+
+	return addr, nil
+}
+
+// checkWebhookSource rejects requests that do not originate from the allowed source ranges, if
+// webhook sources are restricted.
+func (p *Plugin) checkWebhookSource(config *Configuration, r *http.Request) error {
+	if !config.RestrictWebhookSources {
+		return nil
+	}
+
+	addr, err := requestSourceAddress(r, config.trustedProxies)
+	if err != nil {
+		return newWebhookError(http.StatusBadRequest, "could not determine source address: %w", err)
+	}
+
+	if !containsAddr(p.webhookSourceRanges(config), addr) {
+		return newWebhookError(http.StatusForbidden, "source address %s is not allowed to send webhooks", addr)
+	}
+
+	return nil
+}
+
+// webhookSourceRanges returns the configured source ranges, together with the hook ranges
+// published by GitHub if they are refreshed. The first request waits for GitHub's ranges to be
+// fetched, as it would otherwise only be checked against the configured ranges. Later refreshes
+// are started in the background when the ranges are due for one.
+func (p *Plugin) webhookSourceRanges(config *Configuration) []netip.Prefix {
+	ranges := config.webhookSourceRanges
+	if !config.RefreshWebhookSourceRanges || !config.hasGitHubCredentials() {
+		return ranges
+	}
+
+	p.githubHookRanges.lock.Lock()
+	defer p.githubHookRanges.lock.Unlock()
+
+	switch {
+	case p.githubHookRanges.nextRefresh.IsZero():
+		// Concurrent requests wait on the lock until the first fetch is done
+		p.updateGitHubHookRanges(p.fetchGitHubHookRanges())
+	case !p.githubHookRanges.refreshing && time.Now().After(p.githubHookRanges.nextRefresh):
+		p.githubHookRanges.refreshing = true
+		go p.refreshGitHubHookRanges()
+	}
+
+	return append(slices.Clip(ranges), p.githubHookRanges.prefixes...)
+}
+
+// fetchGitHubHookRanges fetches the source ranges of webhook deliveries from GitHub's meta API.
+func (p *Plugin) fetchGitHubHookRanges() ([]netip.Prefix, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), githubHookRangesTimeout)
+	defer cancel()
+
+	meta, _, err := p.githubClient().Meta.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseSourceRanges(strings.Join(meta.Hooks, ","))
+}
+
+// refreshGitHubHookRanges fetches the hook ranges in the background.
+func (p *Plugin) refreshGitHubHookRanges() {
+	prefixes, err := p.fetchGitHubHookRanges()
+
+	p.githubHookRanges.lock.Lock()
+	defer p.githubHookRanges.lock.Unlock()
+
+	p.githubHookRanges.refreshing = false
+	p.updateGitHubHookRanges(prefixes, err)
+}
+
+// updateGitHubHookRanges stores the fetched hook ranges and schedules the next refresh. A failed
+// fetch keeps the previous ranges. It must be called with the lock held.
+func (p *Plugin) updateGitHubHookRanges(prefixes []netip.Prefix, err error) {
+	if err != nil {
+		p.client.Log.Warn("Failed to refresh GitHub hook ranges", "error", err.Error())
+		p.githubHookRanges.nextRefresh = time.Now().Add(githubHookRangesRetry)
+		return
+	}
+
+	p.client.Log.Info("Refreshed GitHub hook ranges", "ranges", len(prefixes))
+	p.githubHookRanges.prefixes = prefixes
+	p.githubHookRanges.nextRefresh = time.Now().Add(githubHookRangesInterval)
+}
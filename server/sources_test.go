@@ -0,0 +1,125 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"reflect"
+	"testing"
+)
+
+func TestParseSourceRanges(t *testing.T) {
+	for name, tc := range map[string]struct {
+		list        string
+		expected    []netip.Prefix
+		expectedErr bool
+	}{
+		"empty": {},
+		"ranges and addresses": {
+			list: "192.30.252.0/22, 2606:50c0::/32\n10.0.0.1 ::ffff:10.0.0.2",
+			expected: []netip.Prefix{
+				netip.MustParsePrefix("192.30.252.0/22"),
+				netip.MustParsePrefix("2606:50c0::/32"),
+				netip.MustParsePrefix("10.0.0.1/32"),
+				netip.MustParsePrefix("10.0.0.2/32"),
+			},
+		},
+		"unmasked range": {
+			list:     "192.30.253.1/22",
+			expected: []netip.Prefix{netip.MustParsePrefix("192.30.252.0/22")},
+		},
+		"invalid range": {
+			list:        "192.30.252.0/40",
+			expectedErr: true,
+		},
+		"invalid address": {
+			list:        "github.com",
+			expectedErr: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			prefixes, err := parseSourceRanges(tc.list)
+			if tc.expectedErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", prefixes)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(prefixes, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, prefixes)
+			}
+		})
+	}
+}
+
+func TestRequestSourceAddress(t *testing.T) {
+	trustedProxies := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+
+	for name, tc := range map[string]struct {
+		remoteAddr   string
+		forwardedFor []string
+		expected     string
+		expectedErr  bool
+	}{
+		"direct": {
+			remoteAddr: "192.30.252.1:4321",
+			expected:   "192.30.252.1",
+		},
+		"ignored from untrusted peer": {
+			remoteAddr:   "203.0.113.1:4321",
+			forwardedFor: []string{"192.30.252.1"},
+			expected:     "203.0.113.1",
+		},
+		"trusted proxy": {
+			remoteAddr:   "10.0.0.1:4321",
+			forwardedFor: []string{"192.30.252.1"},
+			expected:     "192.30.252.1",
+		},
+		"chain of trusted proxies": {
+			remoteAddr:   "10.0.0.1:4321",
+			forwardedFor: []string{"192.30.252.1, 10.0.0.2", "10.0.0.3"},
+			expected:     "192.30.252.1",
+		},
+		"spoofed header": {
+			remoteAddr:   "10.0.0.1:4321",
+			forwardedFor: []string{"192.30.252.1, 203.0.113.1"},
+			expected:     "203.0.113.1",
+		},
+		"ipv6": {
+			remoteAddr: "[2606:50c0::1]:4321",
+			expected:   "2606:50c0::1",
+		},
+		"invalid forwarded address": {
+			remoteAddr:   "10.0.0.1:4321",
+			forwardedFor: []string{"unknown"},
+			expectedErr:  true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/github", nil)
+			r.RemoteAddr = tc.remoteAddr
+			for _, value := range tc.forwardedFor {
+				r.Header.Add("X-Forwarded-For", value)
+			}
+
+			addr, err := requestSourceAddress(r, trustedProxies)
+			if tc.expectedErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", addr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if addr.String() != tc.expected {
+				t.Errorf("expected %s, got %s", tc.expected, addr)
+			}
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/pluginapi"
+	"github.com/mattermost/mattermost/server/public/pluginapi/cluster"
+)
+
+const (
+	staleReminderJobKey      = "stale_reminders"
+	staleReminderKeyPrefix   = "stale_reminder_"
+	staleReminderJobInterval = time.Hour
+	staleReminderStateTTL    = 30 * 24 * time.Hour
+)
+
+// staleReminderState records the reminders sent for a pull request since its last activity, so
+// that they are not sent again after a restart. Once the state expires, pull requests that are
+// still inactive are reminded about again.
+type staleReminderState struct {
+	LastActivity   time.Time `json:"last_activity"`
+	ChannelIDs     []string  `json:"channel_ids"`
+	DirectMessages bool      `json:"direct_messages"`
+}
+
+// staleDaysByTarget returns the number of days after which the pull request is stale in each
+// channel it is routed to. If several routes send it to the same channel, the shortest time wins.
+func staleDaysByTarget(routes []route, event routedEvent, defaultDays int) map[routeTarget]int {
+	days := map[routeTarget]int{}
+	for _, r := range routes {
+		if !r.matches(event) {
+			continue
+		}
+
+		staleDays := r.StaleDays
+		if staleDays == 0 {
+			staleDays = defaultDays
+		}
+		if staleDays <= 0 {
+			continue
+		}
+
+		target := routeTarget{Team: r.Team, Channel: r.Channel}
+		if current, ok := days[target]; !ok || staleDays < current {
+			days[target] = staleDays
+		}
+	}
+
+	return days
+}
+
+// scheduleStaleReminderJob (re)starts the stale pull request reminder job, or stops it if no
+// route has reminders enabled.
+func (p *Plugin) scheduleStaleReminderJob() error {
+	p.jobLock.Lock()
+	defer p.jobLock.Unlock()
+
+	if p.staleReminderJob != nil {
+		if err := p.staleReminderJob.Close(); err != nil {
+			return fmt.Errorf("failed to stop stale reminder job: %w", err)
+		}
+		p.staleReminderJob = nil
+	}
+
+	config := p.getConfiguration()
+	if config.StaleReminderDays <= 0 && !slices.ContainsFunc(config.routes, func(r route) bool {
+		return r.StaleDays > 0
+	}) {
+		return nil
+	}
+
+	job, err := cluster.Schedule(p.API, staleReminderJobKey, cluster.MakeWaitForInterval(staleReminderJobInterval), p.remindStalePullRequests)
+	if err != nil {
+		return fmt.Errorf("failed to schedule stale reminder job: %w", err)
+	}
+	p.staleReminderJob = job
+
+	return nil
+}
+
+// remindStalePullRequests replies to the posts of pull requests that had no activity for longer
+// than their routes allow.
+func (p *Plugin) remindStalePullRequests() {
+	config := p.getConfiguration()
+
+	pullRequests, err := p.getTrackedPullRequests()
+	if err != nil {
+		p.client.Log.Error("Failed to get open pull requests", "error", err.Error())
+		return
+	}
+
+	routes := p.routes()
+	now := time.Now()
+	for key, pullRequest := range pullRequests {
+		if pullRequest.Draft {
+			continue
+		}
+
+		days := staleDaysByTarget(routes, pullRequest.Event, config.StaleReminderDays)
+		for target, staleDays := range days {
+			if now.Sub(pullRequest.LastActivity) <= time.Duration(staleDays)*24*time.Hour {
+				delete(days, target)
+			}
+		}
+		if len(days) == 0 {
+			continue
+		}
+
+		if err = p.remindStalePullRequest(config, key, pullRequest, days); err != nil {
+			p.client.Log.Error("Failed to remind about stale pull request", "pull_request", pullRequest.URL, "error", err.Error())
+		}
+	}
+}
+
+// remindStalePullRequest replies to the pull request's post in every channel it is stale in,
+// unless that was already done since its last activity.
+func (p *Plugin) remindStalePullRequest(config *Configuration, key string, pullRequest trackedPullRequest, targets map[routeTarget]int) error {
+	botUserId := p.botUserId
+	if botUserId == nil {
+		return fmt.Errorf("bot user ID is nil")
+	}
+
+	stateKey := staleReminderKeyPrefix + key
+
+	var state staleReminderState
+	if err := p.client.KV.Get(stateKey, &state); err != nil {
+		return fmt.Errorf("failed to get stale reminder state: %w", err)
+	}
+	if !state.LastActivity.Equal(pullRequest.LastActivity) {
+		state = staleReminderState{LastActivity: pullRequest.LastActivity}
+	}
+
+	obj := pullRequest.object()
+
+	inactiveDays := int(time.Since(pullRequest.LastActivity).Hours() / 24)
+
+	var errs []error
+	reminded := false
+	for target := range targets {
+		channel, err := p.getChannel(target.Team, target.Channel)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if slices.Contains(state.ChannelIDs, channel.Id) {
+			continue
+		}
+
+		// Only pull requests that were posted to the channel are reminded about
+		post, err := p.getIndexedPost(obj, channel.Id)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if post == nil {
+			continue
+		}
+
+		err = p.client.Post.CreatePost(&model.Post{
+			UserId:    *botUserId,
+			ChannelId: channel.Id,
+			RootId:    post.Id,
+			Message:   fmt.Sprintf(":hourglass: This pull request has had no activity for %d days", inactiveDays),
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to reply to post %s for %s: %w", post.Id, obj.Tag, err))
+			continue
+		}
+
+		state.ChannelIDs = append(state.ChannelIDs, channel.Id)
+		reminded = true
+	}
+
+	if config.StaleReminderDirectMessages && !state.DirectMessages && len(state.ChannelIDs) > 0 {
+		message := fmt.Sprintf(":hourglass: [%s#%d %s](%s) has had no activity for %d days",
+			pullRequest.Event.Repository, pullRequest.Number, pullRequest.Title, pullRequest.URL, inactiveDays)
+
+		for _, login := range append([]string{pullRequest.Author}, pullRequest.Reviewers...) {
+			if err := p.sendDirectMessage(login, message); err != nil {
+				errs = append(errs, err)
+			}
+		}
+
+		state.DirectMessages = true
+		reminded = true
+	}
+
+	if reminded {
+		if _, err := p.client.KV.Set(stateKey, state, pluginapi.SetExpiry(staleReminderStateTTL)); err != nil {
+			errs = append(errs, fmt.Errorf("failed to store stale reminder state: %w", err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStaleDaysByTarget(t *testing.T) {
+	event := routedEvent{Event: eventPullRequest, Action: "opened", Repository: "holochain/holochain", Labels: []string{"urgent"}}
+
+	for name, tc := range map[string]struct {
+		routes       []route
+		defaultDays  int
+		expectedDays map[routeTarget]int
+	}{
+		"disabled by default": {
+			routes:       []route{{Team: "core", Channel: "prs"}},
+			expectedDays: map[routeTarget]int{},
+		},
+		"default days": {
+			routes:       []route{{Team: "core", Channel: "prs"}},
+			defaultDays:  7,
+			expectedDays: map[routeTarget]int{{"core", "prs"}: 7},
+		},
+		"route overrides default": {
+			routes:       []route{{StaleDays: 3, Team: "core", Channel: "prs"}},
+			defaultDays:  7,
+			expectedDays: map[routeTarget]int{{"core", "prs"}: 3},
+		},
+		"route disables reminders": {
+			routes:       []route{{StaleDays: -1, Team: "core", Channel: "prs"}},
+			defaultDays:  7,
+			expectedDays: map[routeTarget]int{},
+		},
+		"shortest time per channel wins": {
+			routes: []route{
+				{Team: "core", Channel: "prs"},
+				{Labels: []string{"urgent"}, StaleDays: 1, Team: "core", Channel: "prs"},
+				{Events: []string{eventIssues}, StaleDays: 1, Team: "core", Channel: "issues"},
+			},
+			defaultDays:  7,
+			expectedDays: map[routeTarget]int{{"core", "prs"}: 1},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			days := staleDaysByTarget(tc.routes, event, tc.defaultDays)
+			if !reflect.DeepEqual(days, tc.expectedDays) {
+				t.Errorf("expected days %v, got %v", tc.expectedDays, days)
+			}
+		})
+	}
+}
+
+func TestTrackedPullRequestObject(t *testing.T) {
+	for name, tc := range map[string]struct {
+		pullRequest trackedPullRequest
+		expected    githubObject
+	}{
+		"owner login": {
+			pullRequest: trackedPullRequest{Event: routedEvent{Repository: "holochain/holochain"}, RepoID: 1, Number: 12},
+			expected:    githubObject{RepoID: 1, Kind: githubObjectPullRequest, Number: 12, Tag: "#holochain.holochain.12", LegacyTag: "#.holochain.12"},
+		},
+		"owner name": {
+			pullRequest: trackedPullRequest{Event: routedEvent{Repository: "holo-host/hpos"}, RepoID: 2, Number: 3, OwnerName: "Holo"},
+			expected:    githubObject{RepoID: 2, Kind: githubObjectPullRequest, Number: 3, Tag: "#Holo.hpos.3", LegacyTag: "#Holo.hpos.3"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if obj := tc.pullRequest.object(); obj != tc.expected {
+				t.Errorf("expected %v, got %v", tc.expected, obj)
+			}
+		})
+	}
+}
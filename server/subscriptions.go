@@ -0,0 +1,280 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/pluginapi"
+)
+
+const subscriptionsKey = "subscriptions"
+
+// subscription attaches a repository to a channel. Subscriptions are managed by channel admins
+// with the /hc command, and are routed like the routing rules of the configuration.
+type subscription struct {
+	ChannelID string `json:"channel_id"`
+	// Repository is the repository's full name, or a glob like "holochain/*".
+	Repository string `json:"repository"`
+	// Events are GitHub event types, all supported events if empty.
+	Events []string `json:"events,omitempty"`
+	// Labels match issues and pull requests that have at least one of the labels.
+	Labels    []string `json:"labels,omitempty"`
+	CreatorID string   `json:"creator_id"`
+}
+
+func (s *subscription) route(teamName, channelName string) route {
+	return route{
+		Repositories: []string{s.Repository},
+		Events:       s.Events,
+		Labels:       s.Labels,
+		Team:         teamName,
+		Channel:      channelName,
+	}
+}
+
+// parseSubscription parses the arguments of "/hc subscribe", e.g.
+// "holochain/holochain issues,pull_request --labels bug,security".
+func parseSubscription(args []string) (subscription, error) {
+	var sub subscription
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--labels":
+			if i+1 == len(args) {
+				return subscription{}, errors.New("--labels needs a comma separated list of labels")
+			}
+			i++
+			sub.Labels = splitList(args[i])
+		case strings.HasPrefix(arg, "--labels="):
+			sub.Labels = splitList(strings.TrimPrefix(arg, "--labels="))
+		case strings.HasPrefix(arg, "--"):
+			return subscription{}, fmt.Errorf("unknown option %s", arg)
+		case sub.Repository == "":
+			sub.Repository = strings.ToLower(arg)
+		case sub.Events == nil:
+			sub.Events = splitList(strings.ToLower(arg))
+		default:
+			return subscription{}, fmt.Errorf("unexpected argument %s", arg)
+		}
+	}
+
+	if sub.Repository == "" {
+		return subscription{}, errors.New("a repository is required, e.g. holochain/holochain")
+	}
+	if owner, name, found := strings.Cut(sub.Repository, "/"); !found || owner == "" || name == "" || strings.Contains(name, "/") {
+		return subscription{}, fmt.Errorf("invalid repository %s, expected owner/repo", sub.Repository)
+	}
+
+	r := sub.route("", "")
+	if err := r.validateConditions(); err != nil {
+		return subscription{}, err
+	}
+
+	return sub, nil
+}
+
+// parseSubscriptionRepositories reads the repositories that channels may subscribe to, which are
+// "owner/repo" names or globs like "holochain/*".
+func parseSubscriptionRepositories(list string) ([]string, error) {
+	repositories, err := parseRepositoryList(strings.ToLower(list))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, pattern := range repositories {
+		if _, err = path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid repository pattern %q: %w", pattern, err)
+		}
+	}
+
+	return repositories, nil
+}
+
+// subscriptionAllowed reports whether a channel may subscribe to the repository, or glob of
+// repositories. Any repository is allowed if no repositories are configured.
+func subscriptionAllowed(allowed []string, repository string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+
+	return slices.ContainsFunc(allowed, func(pattern string) bool {
+		matched, _ := path.Match(pattern, strings.ToLower(repository))
+		return matched
+	})
+}
+
+// splitList splits a comma separated list, dropping empty values.
+func splitList(list string) []string {
+	var values []string
+	for _, value := range strings.Split(list, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+
+	return values
+}
+
+// getSubscriptions returns the subscriptions of all channels.
+func (p *Plugin) getSubscriptions() ([]subscription, error) {
+	var subscriptions []subscription
+	if err := p.client.KV.Get(subscriptionsKey, &subscriptions); err != nil {
+		return nil, fmt.Errorf("failed to get subscriptions: %w", err)
+	}
+
+	return subscriptions, nil
+}
+
+// getChannelSubscriptions returns the subscriptions of a channel.
+func (p *Plugin) getChannelSubscriptions(channelID string) ([]subscription, error) {
+	subscriptions, err := p.getSubscriptions()
+	if err != nil {
+		return nil, err
+	}
+
+	return slices.DeleteFunc(subscriptions, func(s subscription) bool {
+		return s.ChannelID != channelID
+	}), nil
+}
+
+// updateSubscriptions atomically replaces the stored subscriptions with the result of update.
+func (p *Plugin) updateSubscriptions(update func(subscriptions []subscription) []subscription) error {
+	err := p.client.KV.SetAtomicWithRetries(subscriptionsKey, func(oldValue []byte) (any, error) {
+		var subscriptions []subscription
+		if oldValue != nil {
+			if err := json.Unmarshal(oldValue, &subscriptions); err != nil {
+				return nil, err
+			}
+		}
+
+		subscriptions = update(subscriptions)
+		if len(subscriptions) == 0 {
+			return nil, nil
+		}
+
+		return subscriptions, nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update subscriptions: %w", err)
+	}
+
+	return nil
+}
+
+// subscribe adds the subscription, replacing an earlier subscription of the channel to the
+// same repository.
+func (p *Plugin) subscribe(sub subscription) error {
+	return p.updateSubscriptions(func(subscriptions []subscription) []subscription {
+		subscriptions = slices.DeleteFunc(subscriptions, func(s subscription) bool {
+			return s.ChannelID == sub.ChannelID && s.Repository == sub.Repository
+		})
+
+		return append(subscriptions, sub)
+	})
+}
+
+// unsubscribe removes the channel's subscription to the repository, and reports whether there
+// was one.
+func (p *Plugin) unsubscribe(channelID, repository string) (bool, error) {
+	var removed bool
+	err := p.updateSubscriptions(func(subscriptions []subscription) []subscription {
+		removed = false
+		return slices.DeleteFunc(subscriptions, func(s subscription) bool {
+			if s.ChannelID == channelID && s.Repository == strings.ToLower(repository) {
+				removed = true
+				return true
+			}
+			return false
+		})
+	})
+
+	return removed, err
+}
+
+// subscriptionRoutes turns the subscriptions into routes. Subscriptions of channels that no
+// longer exist, or to repositories that are no longer allowed, are skipped.
+func (p *Plugin) subscriptionRoutes() ([]route, error) {
+	subscriptions, err := p.getSubscriptions()
+	if err != nil {
+		return nil, err
+	}
+
+	allowed := p.getConfiguration().subscriptionRepositories
+	targets := map[string]*routeTarget{}
+
+	var routes []route
+	for _, sub := range subscriptions {
+		if !subscriptionAllowed(allowed, sub.Repository) {
+			continue
+		}
+
+		target, ok := targets[sub.ChannelID]
+		if !ok {
+			target, err = p.getChannelTarget(sub.ChannelID)
+			if err != nil {
+				return nil, err
+			}
+			targets[sub.ChannelID] = target
+		}
+		if target == nil {
+			continue
+		}
+
+		routes = append(routes, sub.route(target.Team, target.Channel))
+	}
+
+	return routes, nil
+}
+
+// getChannelTarget resolves the team and channel name of a channel, or returns nil if the
+// channel was deleted.
+func (p *Plugin) getChannelTarget(channelID string) (*routeTarget, error) {
+	channel, err := p.client.Channel.Get(channelID)
+	if errors.Is(err, pluginapi.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get channel %s: %w", channelID, err)
+	}
+	if channel.DeleteAt != 0 {
+		return nil, nil
+	}
+
+	team, err := p.client.Team.Get(channel.TeamId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get team %s: %w", channel.TeamId, err)
+	}
+
+	return &routeTarget{Team: team.Name, Channel: channel.Name}, nil
+}
+
+// routes returns the routes of the configuration together with the routes of the channel
+// subscriptions. If the subscriptions cannot be loaded, only the configured routes are used.
+func (p *Plugin) routes() []route {
+	routes := p.getConfiguration().routes
+
+	subscriptionRoutes, err := p.subscriptionRoutes()
+	if err != nil {
+		p.client.Log.Error("Failed to load subscriptions", "error", err.Error())
+		return routes
+	}
+
+	return append(slices.Clip(routes), subscriptionRoutes...)
+}
+
+// canManageSubscriptions reports whether the user may change the subscriptions of the channel,
+// which requires being a channel admin. Managing the channel's roles is only granted to channel,
+// team and system admins by default, unlike managing the channel's properties.
+func (p *Plugin) canManageSubscriptions(userID string, channel *model.Channel) bool {
+	if channel.Type != model.ChannelTypeOpen && channel.Type != model.ChannelTypePrivate {
+		// Direct and group messages cannot be subscribed
+		return false
+	}
+
+	return p.client.User.HasPermissionToChannel(userID, channel.Id, model.PermissionManageChannelRoles)
+}
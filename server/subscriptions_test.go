@@ -0,0 +1,105 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSubscription(t *testing.T) {
+	for name, tc := range map[string]struct {
+		args                 []string
+		expectedSubscription subscription
+		expectedErr          bool
+	}{
+		"repository only": {
+			args:                 []string{"Holochain/Holochain"},
+			expectedSubscription: subscription{Repository: "holochain/holochain"},
+		},
+		"events": {
+			args:                 []string{"holochain/holochain", "issues,pull_request"},
+			expectedSubscription: subscription{Repository: "holochain/holochain", Events: []string{eventIssues, eventPullRequest}},
+		},
+		"labels": {
+			args: []string{"holochain/*", "issues", "--labels", "bug,security"},
+			expectedSubscription: subscription{
+				Repository: "holochain/*",
+				Events:     []string{eventIssues},
+				Labels:     []string{"bug", "security"},
+			},
+		},
+		"labels before events": {
+			args: []string{"holochain/holochain", "--labels=bug", "release"},
+			expectedSubscription: subscription{
+				Repository: "holochain/holochain",
+				Events:     []string{eventRelease},
+				Labels:     []string{"bug"},
+			},
+		},
+		"missing repository": {
+			args:        []string{"--labels", "bug"},
+			expectedErr: true,
+		},
+		"repository without owner": {
+			args:        []string{"holochain"},
+			expectedErr: true,
+		},
+		"unsupported event": {
+			args:        []string{"holochain/holochain", "push"},
+			expectedErr: true,
+		},
+		"missing labels": {
+			args:        []string{"holochain/holochain", "--labels"},
+			expectedErr: true,
+		},
+		"unknown option": {
+			args:        []string{"holochain/holochain", "--branch", "main"},
+			expectedErr: true,
+		},
+		"too many arguments": {
+			args:        []string{"holochain/holochain", "issues", "release"},
+			expectedErr: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			sub, err := parseSubscription(tc.args)
+			if tc.expectedErr {
+				if err == nil {
+					t.Errorf("expected an error, got subscription %v", sub)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(sub, tc.expectedSubscription) {
+				t.Errorf("expected subscription %v, got %v", tc.expectedSubscription, sub)
+			}
+		})
+	}
+}
+
+func TestSubscriptionAllowed(t *testing.T) {
+	allowed, err := parseSubscriptionRepositories("Holochain/*, holo-host/hpos")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for name, tc := range map[string]struct {
+		allowed    []string
+		repository string
+		expected   bool
+	}{
+		"any repository":         {repository: "holo-host/private", expected: true},
+		"repository in glob":     {allowed: allowed, repository: "holochain/holochain", expected: true},
+		"glob in glob":           {allowed: allowed, repository: "holochain/*", expected: true},
+		"listed repository":      {allowed: allowed, repository: "Holo-Host/HPOS", expected: true},
+		"other repository":       {allowed: allowed, repository: "holo-host/private"},
+		"glob of other accounts": {allowed: allowed, repository: "*/*"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if allowed := subscriptionAllowed(tc.allowed, tc.repository); allowed != tc.expected {
+				t.Errorf("expected %v, got %v", tc.expected, allowed)
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/google/go-github/v76/github"
+)
+
+// markdownEscaper escapes the characters that Mattermost would otherwise format as markdown.
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", `*`, `\*`, `_`, `\_`, `~`, `\~`, `#`, `\#`, `|`, `\|`,
+	`[`, `\[`, `]`, `\]`, `(`, `\(`, `)`, `\)`, `<`, `\<`, `>`, `\>`,
+)
+
+// templateString converts a template value to a string. go-github uses pointers for all fields,
+// so templates can pass fields like .Issue.Title to the helpers as they are.
+func templateString(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case *string:
+		if v == nil {
+			return ""
+		}
+		return *v
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// truncateText shortens the text to at most length characters, ending it with an ellipsis if it
+// was shortened.
+func truncateText(length int, value any) string {
+	runes := []rune(templateString(value))
+	if length <= 0 || len(runes) <= length {
+		return string(runes)
+	}
+
+	return string(runes[:max(length-1, 0)]) + "…"
+}
+
+func escapeMarkdown(value any) string {
+	return markdownEscaper.Replace(templateString(value))
+}
+
+// labelList formats the labels' names as a comma separated list of code spans.
+func labelList(labels []*github.Label) string {
+	names := labelNames(labels)
+	if len(names) == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("`%s`", strings.Join(names, "`, `"))
+}
+
+// templateFuncs are the helpers available in message templates.
+func (p *Plugin) templateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"truncate": truncateText,
+		"escape":   escapeMarkdown,
+		"labels":   labelList,
+		"mention": func(login any) string {
+			return p.mention(templateString(login))
+		},
+	}
+}
+
+// parseMessageTemplates reads the message templates, a JSON object mapping an event type, or an
+// event type and action such as "pull_request.opened", to a text/template.
+func (p *Plugin) parseMessageTemplates(value string) (map[string]*template.Template, error) {
+	var sources map[string]string
+	if value = strings.TrimSpace(value); value != "" {
+		if err := json.Unmarshal([]byte(value), &sources); err != nil {
+			return nil, fmt.Errorf("failed to parse message templates: %w", err)
+		}
+	}
+
+	templates := make(map[string]*template.Template, len(sources))
+	for key, source := range sources {
+		event, _, _ := strings.Cut(key, ".")
+		if !slices.Contains(supportedEvents, event) {
+			return nil, fmt.Errorf("invalid message template %q: unsupported event %q, expected one of %s",
+				key, event, strings.Join(supportedEvents, ", "))
+		}
+
+		tmpl, err := template.New(key).Funcs(p.templateFuncs()).Parse(source)
+		if err != nil {
+			return nil, fmt.Errorf("invalid message template %q: %w", key, err)
+		}
+		if err = checkMessageTemplate(tmpl, event); err != nil {
+			return nil, fmt.Errorf("invalid message template %q: %w", key, err)
+		}
+		templates[key] = tmpl
+	}
+
+	return templates, nil
+}
+
+// templateSampleDepth limits how deep the objects of a sample event are allocated, as the
+// go-github types refer to each other in many ways.
+const templateSampleDepth = 4
+
+// checkMessageTemplate executes the template against an empty event of its type, so that fields
+// that do not exist, or are passed to helpers of the wrong type, are reported when the template is
+// configured rather than every time it is used. Mattermost users are not looked up.
+func checkMessageTemplate(tmpl *template.Template, eventName string) error {
+	event, err := github.ParseWebHook(eventName, []byte("{}"))
+	if err != nil {
+		return err
+	}
+	allocateObjects(reflect.ValueOf(event).Elem(), nil)
+
+	check, err := tmpl.Clone()
+	if err != nil {
+		return err
+	}
+	check.Funcs(template.FuncMap{
+		"mention": func(login any) string { return templateString(login) },
+	})
+
+	return check.Execute(io.Discard, event)
+}
+
+// allocateObjects sets the struct's nil pointers to other structs to empty values, so that
+// templates can refer to fields like .Issue.Title without the issue being set. Types that are
+// already on the path are left nil, as they would otherwise be allocated forever.
+func allocateObjects(value reflect.Value, path []reflect.Type) {
+	if len(path) >= templateSampleDepth {
+		return
+	}
+
+	for i := range value.NumField() {
+		field := value.Field(i)
+		if !field.CanSet() || field.Kind() != reflect.Pointer || !field.IsNil() {
+			continue
+		}
+		if field.Type().Elem().Kind() != reflect.Struct || slices.Contains(path, field.Type()) {
+			continue
+		}
+
+		field.Set(reflect.New(field.Type().Elem()))
+		allocateObjects(field.Elem(), append(slices.Clip(path), field.Type()))
+	}
+}
+
+// templateMessage executes the template configured for the event's action, or else for the event
+// type, with the go-github event as data. The object's tag is appended if the template does not
+// include it, so that posts stay searchable by it. False is returned if there is no template, or
+// it fails, in which case the default message should be used.
+func (p *Plugin) templateMessage(eventName, action string, event any, obj githubObject) (string, bool) {
+	templates := p.getConfiguration().messageTemplates
+
+	tmpl, ok := templates[eventName+"."+action]
+	if !ok {
+		tmpl, ok = templates[eventName]
+	}
+	if !ok {
+		return "", false
+	}
+
+	var message strings.Builder
+	if err := tmpl.Execute(&message, event); err != nil {
+		p.client.Log.Warn("Failed to execute message template", "template", tmpl.Name(), "object", obj.Tag, "error", err.Error())
+		return "", false
+	}
+
+	text := strings.TrimSpace(message.String())
+	if !slices.Contains(strings.Fields(text), obj.Tag) {
+		text = strings.TrimSpace(text + "\n" + obj.Tag)
+	}
+
+	return text, true
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/google/go-github/v76/github"
+)
+
+func TestTruncateText(t *testing.T) {
+	for name, tc := range map[string]struct {
+		length   int
+		value    any
+		expected string
+	}{
+		"short":   {length: 10, value: "Fix crash", expected: "Fix crash"},
+		"long":    {length: 6, value: "Fix crash", expected: "Fix c…"},
+		"pointer": {length: 6, value: github.Ptr("Fix crash"), expected: "Fix c…"},
+		"nil":     {length: 6, value: (*string)(nil), expected: ""},
+		"unicode": {length: 3, value: "äöüß", expected: "äö…"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if text := truncateText(tc.length, tc.value); text != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, text)
+			}
+		})
+	}
+}
+
+func TestParseMessageTemplates(t *testing.T) {
+	p := &Plugin{}
+
+	for name, tc := range map[string]struct {
+		templates   string
+		expectedErr bool
+	}{
+		"no templates": {},
+		"valid": {
+			templates: `{"issues": "{{.Issue.Title}}", "pull_request.opened": "{{.PullRequest.Title | truncate 50}}"}`,
+		},
+		"nested fields": {
+			templates: `{"pull_request": "{{.PullRequest.Head.Repo.Owner.Login | mention}} {{labels .PullRequest.Labels}}", "release": "{{.Release.TagName | escape}}"}`,
+		},
+		"unknown field": {
+			templates:   `{"issues": "{{.Issue.Titel}}"}`,
+			expectedErr: true,
+		},
+		"field of another event": {
+			templates:   `{"release": "{{.PullRequest.Title}}"}`,
+			expectedErr: true,
+		},
+		"wrong helper argument": {
+			templates:   `{"issues.labeled": "{{labels .Issue.Title}}"}`,
+			expectedErr: true,
+		},
+		"unsupported event": {
+			templates:   `{"push": "{{.Ref}}"}`,
+			expectedErr: true,
+		},
+		"invalid template": {
+			templates:   `{"issues": "{{.Issue.Title"}`,
+			expectedErr: true,
+		},
+		"unknown function": {
+			templates:   `{"issues": "{{shout .Issue.Title}}"}`,
+			expectedErr: true,
+		},
+		"invalid json": {
+			templates:   `{"issues": 1}`,
+			expectedErr: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			templates, err := p.parseMessageTemplates(tc.templates)
+			if tc.expectedErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", templates)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestTemplateMessage(t *testing.T) {
+	p := &Plugin{}
+	templates, err := p.parseMessageTemplates(`{
+		"pull_request": "{{.PullRequest.Title | escape}}\n{{.PullRequest.HTMLURL}}",
+		"pull_request.closed": "Closed: {{.PullRequest.Title}} {{labels .PullRequest.Labels}}",
+		"issues": "{{.Issue.Title}}\n#holochain.holochain.1"
+	}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	p.setConfiguration(&Configuration{messageTemplates: templates})
+
+	pullRequest := &github.PullRequest{
+		Title:   github.Ptr("Fix *all* crashes"),
+		HTMLURL: github.Ptr("https://github.com/holochain/holochain/pull/12"),
+		Labels:  []*github.Label{{Name: github.Ptr("bug")}},
+	}
+	pullRequestTag := githubObject{Tag: "#holochain.holochain.12"}
+
+	for name, tc := range map[string]struct {
+		eventName  string
+		action     string
+		event      any
+		obj        githubObject
+		expected   string
+		expectedOk bool
+	}{
+		"event template": {
+			eventName:  eventPullRequest,
+			action:     "opened",
+			event:      &github.PullRequestEvent{PullRequest: pullRequest},
+			obj:        pullRequestTag,
+			expected:   "Fix \\*all\\* crashes\nhttps://github.com/holochain/holochain/pull/12\n#holochain.holochain.12",
+			expectedOk: true,
+		},
+		"action template": {
+			eventName:  eventPullRequest,
+			action:     "closed",
+			event:      &github.PullRequestEvent{PullRequest: pullRequest},
+			obj:        pullRequestTag,
+			expected:   "Closed: Fix *all* crashes `bug`\n#holochain.holochain.12",
+			expectedOk: true,
+		},
+		"tag in template": {
+			eventName:  eventIssues,
+			event:      &github.IssuesEvent{Issue: &github.Issue{Title: github.Ptr("Crash")}},
+			obj:        githubObject{Tag: "#holochain.holochain.1"},
+			expected:   "Crash\n#holochain.holochain.1",
+			expectedOk: true,
+		},
+		"no template": {
+			eventName: eventRelease,
+			event:     &github.ReleaseEvent{},
+			obj:       githubObject{Tag: "#holochain.holochain.v0.4.0"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			message, ok := p.templateMessage(tc.eventName, tc.action, tc.event, tc.obj)
+			if ok != tc.expectedOk {
+				t.Fatalf("expected ok to be %v, got %v", tc.expectedOk, ok)
+			}
+			if message != tc.expected {
+				t.Errorf("expected message\n%s\ngot\n%s", tc.expected, message)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/cbrgm/githubevents/v2/githubevents"
+	"github.com/google/go-github/v76/github"
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+// registerPullRequestThreadHandlers posts follow-up events for pull requests as replies to the
+// pull request's post, so that each pull request has a single thread in every channel it was
+// posted to. Events for pull requests that were never posted are ignored.
+func (p *Plugin) registerPullRequestThreadHandlers(eventHandler *githubevents.EventHandler) {
+	eventHandler.OnPullRequestEventSynchronize(
+		func(ctx context.Context, deliveryID string, eventName string, event *github.PullRequestEvent) error {
+			repo := event.GetRepo()
+			obj := pullRequestObject(repo, event.GetPullRequest())
+
+			return p.replyToPosts(ctx, deliveryID, obj, fmt.Sprintf(":arrow_up: %s pushed new commits: [%s](%s/compare/%s...%s)",
+				event.GetSender().GetLogin(),
+				shortSHA(event.GetAfter()),
+				repo.GetHTMLURL(), event.GetBefore(), event.GetAfter()))
+		})
+
+	eventHandler.OnCheckSuiteEventCompleted(
+		func(ctx context.Context, deliveryID string, eventName string, event *github.CheckSuiteEvent) error {
+			repo := event.GetRepo()
+			checkSuite := event.GetCheckSuite()
+
+			message := checkSuiteMessage(repo, checkSuite)
+			if message == "" {
+				return nil
+			}
+
+			// Pull requests from forks are not listed, so their checks cannot be reported
+			var errs []error
+			for _, pullRequest := range checkSuite.PullRequests {
+				if err := p.replyToPosts(ctx, deliveryID, pullRequestObject(repo, pullRequest), message); err != nil {
+					errs = append(errs, err)
+				}
+			}
+
+			return errors.Join(errs...)
+		})
+}
+
+// pullRequestClosedMessage is posted as a reply when a pull request is merged or closed.
+func pullRequestClosedMessage(event *github.PullRequestEvent) string {
+	pullRequest := event.GetPullRequest()
+	if pullRequest.GetMerged() {
+		return fmt.Sprintf(":tada: Merged by %s", pullRequest.GetMergedBy().GetLogin())
+	}
+
+	return fmt.Sprintf(":no_entry_sign: Closed without merging by %s", event.GetSender().GetLogin())
+}
+
+func pullRequestReopenedMessage(event *github.PullRequestEvent) string {
+	return fmt.Sprintf(":arrows_counterclockwise: Reopened by %s", event.GetSender().GetLogin())
+}
+
+// checkSuiteMessage describes the outcome of a check suite, or returns an empty string if the
+// outcome is not worth a reply.
+func checkSuiteMessage(repo *github.Repository, checkSuite *github.CheckSuite) string {
+	var summary string
+	switch checkSuite.GetConclusion() {
+	case "success":
+		summary = ":white_check_mark: %s checks passed on [%s](%s)"
+	case "failure", "timed_out", "startup_failure":
+		summary = ":x: %s checks failed on [%s](%s)"
+	case "cancelled":
+		summary = ":heavy_minus_sign: %s checks were cancelled on [%s](%s)"
+	case "action_required":
+		summary = ":warning: %s checks require action on [%s](%s)"
+	default:
+		// neutral, skipped and stale suites carry no useful information
+		return ""
+	}
+
+	sha := checkSuite.GetHeadSHA()
+
+	return fmt.Sprintf(summary,
+		checkSuite.GetApp().GetName(),
+		shortSHA(sha),
+		fmt.Sprintf("%s/commit/%s/checks", repo.GetHTMLURL(), sha))
+}
+
+func shortSHA(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+
+	return sha
+}
+
+// quote formats text as a markdown block quote.
+func quote(text string) string {
+	return "> " + strings.ReplaceAll(text, "\n", "\n> ")
+}
+
+// replyToPosts replies to the bot's posts about the object in the channels of the event being
+// handled. Posts that were already replied to for the delivery are skipped, so that retries only
+// reply to the posts that failed.
+func (p *Plugin) replyToPosts(ctx context.Context, deliveryID string, obj githubObject, message string) error {
+	botUserId := p.botUserId
+	if botUserId == nil {
+		return fmt.Errorf("bot user ID is nil")
+	}
+
+	posts, err := p.eventPosts(ctx, obj)
+	if err != nil {
+		return fmt.Errorf("failed to find posts for %s: %w", obj.Tag, err)
+	}
+
+	var errs []error
+	for _, post := range posts {
+		claimed, err := p.claimReply(deliveryID, post.Id)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+
+		err = p.client.Post.CreatePost(&model.Post{
+			UserId:    *botUserId,
+			ChannelId: post.ChannelId,
+			RootId:    post.Id,
+			Message:   message,
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to reply to post %s for %s: %w", post.Id, obj.Tag, err))
+
+			if err = p.releaseReply(deliveryID, post.Id); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-github/v76/github"
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+func TestPullRequestClosedMessage(t *testing.T) {
+	sender := &github.User{Login: github.Ptr("octocat")}
+
+	for name, tc := range map[string]struct {
+		pullRequest *github.PullRequest
+		expected    string
+	}{
+		"merged": {
+			pullRequest: &github.PullRequest{Merged: github.Ptr(true), MergedBy: &github.User{Login: github.Ptr("hubot")}},
+			expected:    ":tada: Merged by hubot",
+		},
+		"closed": {
+			pullRequest: &github.PullRequest{Merged: github.Ptr(false)},
+			expected:    ":no_entry_sign: Closed without merging by octocat",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			event := &github.PullRequestEvent{PullRequest: tc.pullRequest, Sender: sender}
+			if message := pullRequestClosedMessage(event); message != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, message)
+			}
+		})
+	}
+}
+
+func TestCheckSuiteMessage(t *testing.T) {
+	repo := &github.Repository{HTMLURL: github.Ptr("https://github.com/holochain/holochain")}
+	sha := "0123456789abcdef"
+	checks := "(https://github.com/holochain/holochain/commit/0123456789abcdef/checks)"
+
+	for name, tc := range map[string]struct {
+		conclusion string
+		expected   string
+	}{
+		"success":         {conclusion: "success", expected: ":white_check_mark: CI checks passed on [0123456]" + checks},
+		"failure":         {conclusion: "failure", expected: ":x: CI checks failed on [0123456]" + checks},
+		"timed out":       {conclusion: "timed_out", expected: ":x: CI checks failed on [0123456]" + checks},
+		"cancelled":       {conclusion: "cancelled", expected: ":heavy_minus_sign: CI checks were cancelled on [0123456]" + checks},
+		"action required": {conclusion: "action_required", expected: ":warning: CI checks require action on [0123456]" + checks},
+		"neutral":         {conclusion: "neutral"},
+		"skipped":         {conclusion: "skipped"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			checkSuite := &github.CheckSuite{
+				Conclusion: github.Ptr(tc.conclusion),
+				HeadSHA:    github.Ptr(sha),
+				App:        &github.App{Name: github.Ptr("CI")},
+			}
+			if message := checkSuiteMessage(repo, checkSuite); message != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, message)
+			}
+		})
+	}
+}
+
+func TestQuote(t *testing.T) {
+	for name, tc := range map[string]struct {
+		text     string
+		expected string
+	}{
+		"single line": {text: "Looks good", expected: "> Looks good"},
+		"multi line":  {text: "Looks good\n\nShip it", expected: "> Looks good\n> \n> Ship it"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if quoted := quote(tc.text); quoted != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, quoted)
+			}
+		})
+	}
+}
+
+func TestReplyToPosts(t *testing.T) {
+	obj := pullRequestObject(testRepo, &github.PullRequest{Number: github.Ptr(1)})
+
+	for name, tc := range map[string]struct {
+		deliveryIDs     []string
+		failing         bool
+		expectedReplies int
+	}{
+		"one delivery":         {deliveryIDs: []string{"a"}, expectedReplies: 1},
+		"retried delivery":     {deliveryIDs: []string{"a", "a"}, expectedReplies: 1},
+		"retry after failure":  {deliveryIDs: []string{"a", "a"}, failing: true, expectedReplies: 1},
+		"different deliveries": {deliveryIDs: []string{"a", "b"}, expectedReplies: 2},
+		"no delivery id":       {deliveryIDs: []string{"", ""}, expectedReplies: 2},
+	} {
+		t.Run(name, func(t *testing.T) {
+			p, api := newTestPlugin()
+			prs := createIndexedPost(t, p, obj, "prs", textContent(obj.Tag), true)
+			ready := createIndexedPost(t, p, obj, "ready", textContent(obj.Tag), true)
+
+			// The first attempt fails for one of the channels
+			api.failChannels = map[string]bool{"ready": tc.failing}
+			if err := p.replyToPosts(context.Background(), tc.deliveryIDs[0], obj, ":tada: Merged by octocat"); (err != nil) != tc.failing {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			api.failChannels = nil
+			for _, deliveryID := range tc.deliveryIDs[1:] {
+				if err := p.replyToPosts(context.Background(), deliveryID, obj, ":tada: Merged by octocat"); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+
+			for _, post := range []*model.Post{prs, ready} {
+				if replies := api.replies(post.Id); len(replies) != tc.expectedReplies {
+					t.Errorf("expected %d replies in channel %s, got %v", tc.expectedReplies, post.ChannelId, replies)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/pluginapi"
+)
+
+// parseUserMapping reads "github-login=mattermost-username" pairs separated by commas or new
+// lines. GitHub logins are case insensitive, so they are stored in lower case.
+func parseUserMapping(mapping string) (map[string]string, error) {
+	users := map[string]string{}
+	for _, pair := range strings.FieldsFunc(mapping, func(r rune) bool {
+		return r == ',' || r == '\n'
+	}) {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		login, username, found := strings.Cut(pair, "=")
+		login = strings.TrimSpace(login)
+		username = strings.TrimPrefix(strings.TrimSpace(username), "@")
+		if !found || login == "" || username == "" {
+			return nil, fmt.Errorf("invalid user mapping %q, expected github-login=mattermost-username", pair)
+		}
+
+		users[strings.ToLower(login)] = username
+	}
+
+	return users, nil
+}
+
+// getMattermostUser returns the Mattermost user for a GitHub login, or nil if the login is
+// unknown. Accounts linked by users take precedence over the mapping configured by admins.
+func (p *Plugin) getMattermostUser(login string) (*model.User, error) {
+	if login == "" {
+		return nil, nil
+	}
+
+	userID, err := p.getLinkedUserID(login)
+	if err != nil {
+		return nil, err
+	}
+	if userID != "" {
+		user, err := p.client.User.Get(userID)
+		if err == nil {
+			return user, nil
+		}
+		if !errors.Is(err, pluginapi.ErrNotFound) {
+			return nil, fmt.Errorf("failed to get Mattermost user %s: %w", userID, err)
+		}
+	}
+
+	username, ok := p.getConfiguration().userMapping[strings.ToLower(login)]
+	if !ok {
+		return nil, nil
+	}
+
+	user, err := p.client.User.GetByUsername(username)
+	if errors.Is(err, pluginapi.ErrNotFound) {
+		p.client.Log.Warn("Mapped Mattermost user does not exist", "github_login", login, "username", username)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Mattermost user %s: %w", username, err)
+	}
+
+	return user, nil
+}
+
+// mention returns an @mention of the Mattermost user for the GitHub login, or the login itself
+// if there is no such user.
+func (p *Plugin) mention(login string) string {
+	user, err := p.getMattermostUser(login)
+	if err != nil {
+		p.client.Log.Warn("Failed to look up Mattermost user", "github_login", login, "error", err.Error())
+		return login
+	}
+	if user == nil {
+		return login
+	}
+
+	return "@" + user.Username
+}
+
+// sendDirectMessage sends a direct message from the bot to the Mattermost user that the GitHub
+// login is mapped to. Nothing is sent for logins that are not mapped.
+func (p *Plugin) sendDirectMessage(login, message string) error {
+	botUserId := p.botUserId
+	if botUserId == nil {
+		return fmt.Errorf("bot user ID is nil")
+	}
+
+	user, err := p.getMattermostUser(login)
+	if err != nil || user == nil {
+		return err
+	}
+
+	if err = p.client.Post.DM(*botUserId, user.Id, &model.Post{Message: message}); err != nil {
+		return fmt.Errorf("failed to send direct message to %s: %w", user.Username, err)
+	}
+
+	return nil
+}
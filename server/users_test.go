@@ -0,0 +1,109 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseUserMapping(t *testing.T) {
+	for name, tc := range map[string]struct {
+		mapping       string
+		expected      map[string]string
+		expectedError bool
+	}{
+		"empty": {
+			mapping:  "",
+			expected: map[string]string{},
+		},
+		"commas and new lines": {
+			mapping:  "octocat=octo, Hubot=@hubot\n\nmonalisa = mona\n",
+			expected: map[string]string{"octocat": "octo", "hubot": "hubot", "monalisa": "mona"},
+		},
+		"missing username": {
+			mapping:       "octocat=",
+			expectedError: true,
+		},
+		"missing separator": {
+			mapping:       "octocat",
+			expectedError: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			users, err := parseUserMapping(tc.mapping)
+			if tc.expectedError {
+				if err == nil {
+					t.Errorf("expected an error, got %v", users)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(users, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, users)
+			}
+		})
+	}
+}
+
+func TestMention(t *testing.T) {
+	for name, tc := range map[string]struct {
+		mapping  map[string]string
+		links    map[string]string
+		login    string
+		expected string
+	}{
+		"unknown login": {
+			login:    "octocat",
+			expected: "octocat",
+		},
+		"mapped login": {
+			mapping:  map[string]string{"octocat": "octo"},
+			login:    "OctoCat",
+			expected: "@octo",
+		},
+		"mapped user does not exist": {
+			mapping:  map[string]string{"octocat": "nobody"},
+			login:    "octocat",
+			expected: "octocat",
+		},
+		"linked login": {
+			links:    map[string]string{"octocat": "user1"},
+			login:    "octocat",
+			expected: "@linked",
+		},
+		"link takes precedence": {
+			mapping:  map[string]string{"octocat": "octo"},
+			links:    map[string]string{"octocat": "user1"},
+			login:    "octocat",
+			expected: "@linked",
+		},
+		"linked user was deleted": {
+			mapping:  map[string]string{"octocat": "octo"},
+			links:    map[string]string{"octocat": "deleted"},
+			login:    "octocat",
+			expected: "@octo",
+		},
+		"no login": {
+			mapping:  map[string]string{"": "octo"},
+			expected: "",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			p, api := newTestPlugin()
+			api.addUser("user1", "linked")
+			api.addUser("user2", "octo")
+			p.setConfiguration(&Configuration{userMapping: tc.mapping})
+
+			for login, userID := range tc.links {
+				if err := p.linkAccounts(userID, login); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+
+			if mention := p.mention(tc.login); mention != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, mention)
+			}
+		})
+	}
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v76/github"
+)
+
+// webhookHandlers names the parts of the plugin that handle each GitHub event type, which are
+// reported back to GitHub so that its "Recent deliveries" view shows what a delivery was used for.
+var webhookHandlers = map[string][]string{
+	"issues":                      {"issues", "issue lifecycle", "activity"},
+	"pull_request":                {"pull requests", "pull request threads", "review requests", "activity"},
+	"pull_request_review":         {"reviews", "activity"},
+	"pull_request_review_comment": {"activity"},
+	"check_suite":                 {"pull request threads", "ci alerts"},
+	"workflow_run":                {"ci alerts"},
+	"release":                     {"releases", "activity"},
+}
+
+// Statuses of a delivery reported in webhook responses.
+const (
+	webhookStatusQueued    = "queued"
+	webhookStatusDuplicate = "duplicate"
+	webhookStatusIgnored   = "ignored"
+	webhookStatusPong      = "pong"
+	webhookStatusError     = "error"
+)
+
+// webhookResponse is the JSON body of the response to a webhook request.
+type webhookResponse struct {
+	Status     string   `json:"status"`
+	DeliveryID string   `json:"delivery_id,omitempty"`
+	Event      string   `json:"event,omitempty"`
+	Action     string   `json:"action,omitempty"`
+	Handlers   []string `json:"handlers,omitempty"`
+	Error      string   `json:"error,omitempty"`
+}
+
+// webhookError is an error with the HTTP status code that it is reported with.
+type webhookError struct {
+	status int
+	err    error
+}
+
+func (e *webhookError) Error() string {
+	return e.err.Error()
+}
+
+func (e *webhookError) Unwrap() error {
+	return e.err
+}
+
+func newWebhookError(status int, format string, args ...any) error {
+	return &webhookError{status: status, err: fmt.Errorf(format, args...)}
+}
+
+// webhookErrorStatus returns the HTTP status code for an error, which is 500 unless the error
+// says otherwise.
+func webhookErrorStatus(err error) int {
+	var webhookErr *webhookError
+	if errors.As(err, &webhookErr) {
+		return webhookErr.status
+	}
+
+	return http.StatusInternalServerError
+}
+
+// eventHandlers returns the handlers of an event type that are enabled in the configuration.
+func eventHandlers(config *Configuration, eventName string, endpoint *webhookEndpoint) []string {
+	return slices.DeleteFunc(slices.Clone(webhookHandlers[eventName]), func(handler string) bool {
+		switch handler {
+		case "ci alerts":
+			return config.ciTarget == nil || endpoint != nil
+		case "activity":
+			return endpoint != nil
+		default:
+			return false
+		}
+	})
+}
+
+// webhookSecret is a secret that webhook signatures are accepted for, until it expires.
+type webhookSecret struct {
+	// Name identifies the secret in logs without revealing it.
+	Name   string
+	Secret string
+	// Expires is zero if the secret does not expire.
+	Expires time.Time
+}
+
+func (s webhookSecret) active(now time.Time) bool {
+	return s.Expires.IsZero() || now.Before(s.Expires)
+}
+
+// parseWebhookSecrets returns the current secret followed by the previous secrets, which are given
+// one per line, each optionally followed by the date or RFC 3339 time it expires at. Previous
+// secrets keep deliveries working while the secret is changed on GitHub.
+func parseWebhookSecrets(current, previousSecrets string) ([]webhookSecret, error) {
+	var secrets []webhookSecret
+	if current != "" {
+		secrets = append(secrets, webhookSecret{Name: "current", Secret: current})
+	}
+
+	previous := 0
+	for line := range strings.Lines(previousSecrets) {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		previous++
+		if len(fields) > 2 {
+			return nil, fmt.Errorf("invalid previous webhook secret #%d, expected a secret and an optional expiry time", previous)
+		}
+
+		secret := webhookSecret{Name: fmt.Sprintf("previous #%d", previous), Secret: fields[0]}
+
+		if len(fields) == 2 {
+			expires, err := parseExpiry(fields[1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid expiry time of %s webhook secret: %w", secret.Name, err)
+			}
+			secret.Expires = expires
+		}
+
+		secrets = append(secrets, secret)
+	}
+
+	return secrets, nil
+}
+
+// parseExpiry reads an RFC 3339 time, or a date that is taken to start at midnight UTC.
+func parseExpiry(value string) (time.Time, error) {
+	if expires, err := time.Parse(time.DateOnly, value); err == nil {
+		return expires, nil
+	}
+
+	return time.Parse(time.RFC3339, value)
+}
+
+var errMissingWebhookSecret = errors.New("a webhook secret is required, unless unsigned webhooks are allowed for development")
+
+// checkWebhookSecrets returns an error if no webhook secrets are configured, as anyone could then
+// post events to the publicly reachable endpoint. Webhook endpoints with their own secrets are
+// enough to handle events, in which case only requests to the endpoints are accepted.
+func checkWebhookSecrets(config *Configuration) error {
+	if len(config.webhookSecrets) == 0 && len(config.webhookEndpoints) == 0 && !config.AllowUnsignedWebhooks {
+		return errMissingWebhookSecret
+	}
+
+	return nil
+}
+
+// readWebhookPayload reads the JSON payload of a webhook request and checks its signature against
+// each of the secrets that are still active, returning the secret that matched. Unsigned requests
+// are only accepted if no secrets are configured at all.
+func readWebhookPayload(r *http.Request, secrets []webhookSecret, now time.Time) ([]byte, *webhookSecret, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, nil, newWebhookError(http.StatusBadRequest, "could not read webhook payload: %w", err)
+	}
+
+	contentType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, nil, newWebhookError(http.StatusBadRequest, "invalid content type: %w", err)
+	}
+
+	signature := r.Header.Get(github.SHA256SignatureHeader)
+	if signature == "" {
+		signature = r.Header.Get(github.SHA1SignatureHeader)
+	}
+
+	var matched *webhookSecret
+	if len(secrets) > 0 || signature != "" {
+		for _, secret := range secrets {
+			if !secret.active(now) {
+				continue
+			}
+
+			if err = github.ValidateSignature(signature, body, []byte(secret.Secret)); err == nil {
+				matched = &secret
+				break
+			}
+		}
+
+		if matched == nil {
+			return nil, nil, newWebhookError(http.StatusUnauthorized, "webhook signature does not match any active secret")
+		}
+	}
+
+	// The signature has been checked above, so it is not checked again here
+	payload, err := github.ValidatePayloadFromBody(contentType, bytes.NewReader(body), "", nil)
+	if err != nil {
+		return nil, nil, newWebhookError(http.StatusBadRequest, "could not read webhook payload: %w", err)
+	}
+
+	return payload, matched, nil
+}
+
+// payloadAction returns the action of an event payload, if it has one.
+func payloadAction(payload []byte) string {
+	var event struct {
+		Action string `json:"action"`
+	}
+	_ = json.Unmarshal(payload, &event)
+
+	return event.Action
+}
+
+// writeWebhookResponse writes the response as JSON with the status code.
+func (p *Plugin) writeWebhookResponse(w http.ResponseWriter, status int, response *webhookResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		p.client.Log.Warn("Failed to write webhook response", "error", err.Error())
+	}
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func signature(secret, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestParseWebhookSecrets(t *testing.T) {
+	for name, tc := range map[string]struct {
+		current     string
+		previous    string
+		expected    []webhookSecret
+		expectedErr bool
+	}{
+		"no secrets": {},
+		"current only": {
+			current:  "new",
+			expected: []webhookSecret{{Name: "current", Secret: "new"}},
+		},
+		"previous with expiry": {
+			current:  "new",
+			previous: "old 2024-06-30\n\nolder 2024-06-01T12:00:00+02:00\noldest\n",
+			expected: []webhookSecret{
+				{Name: "current", Secret: "new"},
+				{Name: "previous #1", Secret: "old", Expires: time.Date(2024, 6, 30, 0, 0, 0, 0, time.UTC)},
+				{Name: "previous #2", Secret: "older", Expires: time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC)},
+				{Name: "previous #3", Secret: "oldest"},
+			},
+		},
+		"invalid expiry": {
+			current:     "new",
+			previous:    "old tomorrow",
+			expectedErr: true,
+		},
+		"too many fields": {
+			current:     "new",
+			previous:    "old 2024-06-30 extra",
+			expectedErr: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			secrets, err := parseWebhookSecrets(tc.current, tc.previous)
+			if tc.expectedErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", secrets)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(secrets) != len(tc.expected) {
+				t.Fatalf("expected %v, got %v", tc.expected, secrets)
+			}
+			for i, secret := range secrets {
+				expected := tc.expected[i]
+				if secret.Name != expected.Name || secret.Secret != expected.Secret || !secret.Expires.Equal(expected.Expires) {
+					t.Errorf("expected %v, got %v", expected, secret)
+				}
+			}
+		})
+	}
+}
+
+func TestReadWebhookPayload(t *testing.T) {
+	payload := `{"action":"opened"}`
+	form := url.Values{"payload": {payload}}.Encode()
+	now := time.Date(2024, 6, 15, 0, 0, 0, 0, time.UTC)
+	secrets := []webhookSecret{
+		{Name: "current", Secret: "secret"},
+		{Name: "previous #1", Secret: "old", Expires: now.Add(time.Hour)},
+		{Name: "previous #2", Secret: "expired", Expires: now},
+	}
+
+	for name, tc := range map[string]struct {
+		secrets        []webhookSecret
+		contentType    string
+		signature      string
+		body           string
+		expected       string
+		expectedSecret string
+		expectedStatus int
+	}{
+		"signed json": {
+			secrets:        secrets,
+			contentType:    "application/json",
+			signature:      signature("secret", payload),
+			body:           payload,
+			expected:       payload,
+			expectedSecret: "current",
+		},
+		"signed form": {
+			secrets:        secrets,
+			contentType:    "application/x-www-form-urlencoded",
+			signature:      signature("secret", form),
+			body:           form,
+			expected:       payload,
+			expectedSecret: "current",
+		},
+		"previous secret": {
+			secrets:        secrets,
+			contentType:    "application/json",
+			signature:      signature("old", payload),
+			body:           payload,
+			expected:       payload,
+			expectedSecret: "previous #1",
+		},
+		"expired secret": {
+			secrets:        secrets,
+			contentType:    "application/json",
+			signature:      signature("expired", payload),
+			body:           payload,
+			expectedStatus: http.StatusUnauthorized,
+		},
+		"unsigned with expired secrets only": {
+			secrets:        secrets[2:],
+			contentType:    "application/json",
+			body:           payload,
+			expectedStatus: http.StatusUnauthorized,
+		},
+		"unsigned without secret": {
+			contentType: "application/json",
+			body:        payload,
+			expected:    payload,
+		},
+		"wrong secret": {
+			secrets:        secrets,
+			contentType:    "application/json",
+			signature:      signature("other", payload),
+			body:           payload,
+			expectedStatus: http.StatusUnauthorized,
+		},
+		"missing signature": {
+			secrets:        secrets,
+			contentType:    "application/json",
+			body:           payload,
+			expectedStatus: http.StatusUnauthorized,
+		},
+		"unsupported content type": {
+			secrets:        secrets,
+			contentType:    "text/plain",
+			signature:      signature("secret", payload),
+			body:           payload,
+			expectedStatus: http.StatusBadRequest,
+		},
+		"missing content type": {
+			secrets:        secrets,
+			signature:      signature("secret", payload),
+			body:           payload,
+			expectedStatus: http.StatusBadRequest,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/github", strings.NewReader(tc.body))
+			if tc.contentType != "" {
+				r.Header.Set("Content-Type", tc.contentType)
+			}
+			if tc.signature != "" {
+				r.Header.Set("X-Hub-Signature-256", tc.signature)
+			}
+
+			result, secret, err := readWebhookPayload(r, tc.secrets, now)
+			if tc.expectedStatus != 0 {
+				if err == nil {
+					t.Fatalf("expected an error, got %s", result)
+				}
+				if status := webhookErrorStatus(err); status != tc.expectedStatus {
+					t.Errorf("expected status %d, got %d", tc.expectedStatus, status)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(result) != tc.expected {
+				t.Errorf("expected %s, got %s", tc.expected, result)
+			}
+			if tc.expectedSecret == "" && secret != nil {
+				t.Errorf("expected no secret, got %s", secret.Name)
+			}
+			if tc.expectedSecret != "" && (secret == nil || secret.Name != tc.expectedSecret) {
+				t.Errorf("expected secret %s, got %v", tc.expectedSecret, secret)
+			}
+		})
+	}
+}
+
+func TestEventHandlers(t *testing.T) {
+	for name, tc := range map[string]struct {
+		config    *Configuration
+		eventName string
+		endpoint  *webhookEndpoint
+		expected  []string
+	}{
+		"issues": {
+			config:    &Configuration{},
+			eventName: "issues",
+			expected:  []string{"issues", "issue lifecycle", "activity"},
+		},
+		"ci disabled": {
+			config:    &Configuration{},
+			eventName: "check_suite",
+			expected:  []string{"pull request threads"},
+		},
+		"ci enabled": {
+			config:    &Configuration{ciTarget: &routeTarget{Team: "holochain", Channel: "ci"}},
+			eventName: "workflow_run",
+			expected:  []string{"ci alerts"},
+		},
+		"endpoint": {
+			config:    &Configuration{ciTarget: &routeTarget{Team: "holochain", Channel: "ci"}},
+			eventName: "issues",
+			endpoint:  &webhookEndpoint{ID: "lair"},
+			expected:  []string{"issues", "issue lifecycle"},
+		},
+		"endpoint ci": {
+			config:    &Configuration{ciTarget: &routeTarget{Team: "holochain", Channel: "ci"}},
+			eventName: "workflow_run",
+			endpoint:  &webhookEndpoint{ID: "lair"},
+			expected:  []string{},
+		},
+		"unhandled event": {
+			config:    &Configuration{},
+			eventName: "star",
+			expected:  nil,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if handlers := eventHandlers(tc.config, tc.eventName, tc.endpoint); !reflect.DeepEqual(handlers, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, handlers)
+			}
+		})
+	}
+}
+
+func TestCheckWebhookSecrets(t *testing.T) {
+	for name, tc := range map[string]struct {
+		config      *Configuration
+		expectedErr bool
+	}{
+		"secret": {
+			config: &Configuration{webhookSecrets: []webhookSecret{{Name: "current", Secret: "secret"}}},
+		},
+		"no secret": {
+			config:      &Configuration{},
+			expectedErr: true,
+		},
+		"unsigned allowed": {
+			config: &Configuration{AllowUnsignedWebhooks: true},
+		},
+		"endpoints only": {
+			config: &Configuration{webhookEndpoints: []webhookEndpoint{{ID: "lair", Secret: "secret"}}},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := checkWebhookSecrets(tc.config)
+			if tc.expectedErr && err == nil {
+				t.Fatal("expected an error, got nil")
+			}
+			if !tc.expectedErr && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}